@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------- HTTP 接口 ---------- */
+
+// HTTPConfig 内置 HTTP 服务，Listen 为空时不启动
+type HTTPConfig struct {
+	Listen string `ini:"listen"` // 监听地址，如 127.0.0.1:8080
+}
+
+// 查询限制，避免一次读取过多数据
+const (
+	maxStatsMinutes = 7 * 24 * 60
+	defaultTickRows = 1000
+	maxTickRows     = 10000
+)
+
+// apiServer 只读接口，数据来自监控循环和数据库
+type apiServer struct {
+	monitor *Monitor
+	log     func(string)
+	done    <-chan struct{} // 服务关闭时结束推送连接
+}
+
+func (s *apiServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/quote", s.handleQuote)
+	mux.HandleFunc("GET /api/stats", s.handleStats)
+	mux.HandleFunc("GET /api/ticks", s.handleTicks)
+	mux.HandleFunc("GET /api/candles", s.handleCandles)
+	mux.HandleFunc("GET /api/config", s.handleConfig)
+	mux.HandleFunc("GET /api/stream", s.handleStream)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+// startHTTPServer 按配置启动 HTTP 服务，ctx 取消后关闭
+func startHTTPServer(ctx context.Context, monitor *Monitor, log func(string)) {
+	if cfg.HTTP.Listen == "" {
+		return
+	}
+	s := &apiServer{monitor: monitor, log: log, done: ctx.Done()}
+	srv := &http.Server{
+		Addr:              cfg.HTTP.Listen,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log("HTTP 接口已启动: " + cfg.HTTP.Listen)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log(fmt.Sprintf("HTTP 接口启动失败: %v", err))
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// instrumentParam 品种参数，为空时取第一个品种
+func instrumentParam(r *http.Request) (string, error) {
+	name := strings.TrimSpace(r.URL.Query().Get("instrument"))
+	if name == "" {
+		return cfg.Instruments[0], nil
+	}
+	if !slices.Contains(cfg.Instruments, name) {
+		return "", fmt.Errorf("未监控的品种: %s", name)
+	}
+	return name, nil
+}
+
+// timeParam 时间参数，可为毫秒时间戳、RFC3339 或本地日期，为空时返回 def
+func timeParam(r *http.Request, key string, def time.Time) (time.Time, error) {
+	v := strings.TrimSpace(r.URL.Query().Get(key))
+	if v == "" {
+		return def, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(backtestDateLayout, v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s 应为毫秒时间戳、RFC3339 或 %s", key, backtestDateLayout)
+}
+
+// intParam 整数参数，为空时返回 def，需在 [lo, hi] 内
+func intParam(r *http.Request, key string, def, lo, hi int) (int, error) {
+	v := strings.TrimSpace(r.URL.Query().Get(key))
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("%s 需为 %d 到 %d 之间的整数", key, lo, hi)
+	}
+	return n, nil
+}
+
+// quoteJSON 报价，时间同时给出毫秒时间戳和 RFC3339
+type quoteJSON struct {
+	Instrument string  `json:"instrument"`
+	TS         int64   `json:"ts"`
+	Time       string  `json:"time"`
+	Last       float64 `json:"last"`
+	Bid        float64 `json:"bid"`
+	Ask        float64 `json:"ask"`
+	Open       float64 `json:"open"`
+	High       float64 `json:"high"`
+	Low        float64 `json:"low"`
+	Change     float64 `json:"change"`
+}
+
+func newQuoteJSON(instrument string, q *PriceQuote) quoteJSON {
+	return quoteJSON{
+		Instrument: instrument,
+		TS:         q.T.UnixMilli(),
+		Time:       q.T.Format(time.RFC3339),
+		Last:       q.Last,
+		Bid:        q.Bid,
+		Ask:        q.Ask,
+		Open:       q.Open,
+		High:       q.High,
+		Low:        q.Low,
+		Change:     q.Change,
+	}
+}
+
+// handleQuote 最新报价，不指定品种时返回所有品种。监控循环尚未取到价格时取数据库中的最后一条
+func (s *apiServer) handleQuote(w http.ResponseWriter, r *http.Request) {
+	names := cfg.Instruments
+	if r.URL.Query().Get("instrument") != "" {
+		name, err := instrumentParam(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		names = []string{name}
+	}
+
+	type quoteResp struct {
+		quoteJSON
+		Profit    *float64 `json:"profit,omitempty"`
+		BreakEven *float64 `json:"break_even,omitempty"`
+		Source    string   `json:"source"` // live/db
+	}
+	var resp []quoteResp
+	for _, name := range names {
+		if lq, ok := s.monitor.latestQuote(name); ok {
+			resp = append(resp, quoteResp{newQuoteJSON(name, lq.Quote), &lq.Profit, &lq.BreakEven, "live"})
+			continue
+		}
+		q, err := loadLatestQuote(name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if q != nil {
+			resp = append(resp, quoteResp{quoteJSON: newQuoteJSON(name, q), Source: "db"})
+		}
+	}
+	if len(resp) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("暂无报价"))
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleStats 最近 minutes 分钟的统计，与监控日志中的 max/min/avg/med 算法相同
+func (s *apiServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	name, err := instrumentParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	def := 10
+	if t := s.monitor.currentSettings().Targets[name]; t.StatsMinutes > 0 {
+		def = t.StatsMinutes
+	}
+	minutes, err := intParam(r, "minutes", def, 1, maxStatsMinutes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now()
+	recLis := getRecentPriceData(name, now.Add(-time.Duration(minutes)*time.Minute))
+	if len(recLis) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s 最近 %d 分钟没有价格记录", name, minutes))
+		return
+	}
+	maxVal, minVal, avgVal, medVal := getStatsPrice(recLis, int64(minutes), now.Unix())
+	stdVal, firstVal := getStdPrice(recLis, int64(minutes), now.Unix())
+	writeJSON(w, http.StatusOK, map[string]any{
+		"instrument": name,
+		"minutes":    minutes,
+		"count":      len(recLis),
+		"max":        maxVal,
+		"min":        minVal,
+		"avg":        avgVal,
+		"med":        medVal,
+		"std":        stdVal,
+		"first":      firstVal,
+	})
+}
+
+// handleTicks 原始报价记录，默认最近 1 小时，超过 limit 条时只返回最早的 limit 条
+func (s *apiServer) handleTicks(w http.ResponseWriter, r *http.Request) {
+	name, err := instrumentParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	now := time.Now()
+	to, err := timeParam(r, "to", now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	from, err := timeParam(r, "from", to.Add(-time.Hour))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := intParam(r, "limit", defaultTickRows, 1, maxTickRows)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	quotes, err := loadTicks(name, from, to, limit+1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	truncated := len(quotes) > limit
+	if truncated {
+		quotes = quotes[:limit]
+	}
+	ticks := make([]quoteJSON, 0, len(quotes))
+	for _, q := range quotes {
+		ticks = append(ticks, newQuoteJSON(name, q))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"instrument": name,
+		"from":       from.UnixMilli(),
+		"to":         to.UnixMilli(),
+		"truncated":  truncated,
+		"ticks":      ticks,
+	})
+}
+
+// handleCandles K线，interval 如 1m、5m、1h、1d，默认最近 1 天的 15 分钟K线
+func (s *apiServer) handleCandles(w http.ResponseWriter, r *http.Request) {
+	name, err := instrumentParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	interval := 15 * time.Minute
+	if v := r.URL.Query().Get("interval"); v != "" {
+		if interval, err = parseCandleInterval(v); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	to, err := timeParam(r, "to", time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	from, err := timeParam(r, "from", to.Add(-24*time.Hour))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if to.Sub(from)/interval > maxTickRows {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("K线数量超过 %d，请缩小时间范围或加大周期", maxTickRows))
+		return
+	}
+
+	// 周期已由 parseCandleInterval 校验，这里的错误都来自数据库
+	candles, err := getCandles(name, interval, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	type candleJSON struct {
+		TS    int64   `json:"ts"`
+		Time  string  `json:"time"`
+		Open  float64 `json:"open"`
+		High  float64 `json:"high"`
+		Low   float64 `json:"low"`
+		Close float64 `json:"close"`
+		Count int     `json:"count"`
+	}
+	out := make([]candleJSON, 0, len(candles))
+	for _, k := range candles {
+		out = append(out, candleJSON{k.T, time.UnixMilli(k.T).Format(time.RFC3339), k.Open, k.High, k.Low, k.Close, k.Count})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"instrument": name,
+		"interval":   interval.String(),
+		"candles":    out,
+	})
+}
+
+// handleConfig 当前使用的提醒参数、手续费、自定义规则和策略，不含通知渠道的密钥
+func (s *apiServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	st := s.monitor.currentSettings()
+	rules, err := loadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	type targetJSON struct {
+		BuyPrice        float64 `json:"buy_price"`
+		TargetBuyPrice  float64 `json:"target_buy"`
+		TargetSellPrice float64 `json:"target_sell"`
+		StatsMinutes    int     `json:"stats"`
+		DropPct         float64 `json:"drop_pct"`
+		RisePct         float64 `json:"rise_pct"`
+		StdMult         float64 `json:"std_mult"`
+		VolWindow       int     `json:"vol_window"`
+		ProfitYuan      float64 `json:"profit_yuan"`
+		ProfitPct       float64 `json:"profit_pct"`
+		BreakEvenAlert  bool    `json:"break_even_alert"`
+	}
+	type feeJSON struct {
+		Pct   float64 `json:"pct"`
+		Fixed float64 `json:"fixed"`
+		Min   float64 `json:"min"`
+	}
+	type instrumentJSON struct {
+		Name     string     `json:"name"`
+		Target   targetJSON `json:"target"`
+		BuyFee   feeJSON    `json:"buy_fee"`
+		SellFee  feeJSON    `json:"sell_fee"`
+		Notional float64    `json:"notional"`
+	}
+	type ruleJSON struct {
+		ID         int64  `json:"id"`
+		Name       string `json:"name"`
+		Instrument string `json:"instrument"`
+		Expr       string `json:"expr"`
+		Channels   string `json:"channels"`
+		Cooldown   int    `json:"cooldown"`
+		Enabled    bool   `json:"enabled"`
+	}
+
+	instruments := make([]instrumentJSON, 0, len(cfg.Instruments))
+	for _, name := range cfg.Instruments {
+		t := st.Targets[name]
+		fs := feesFor(name)
+		instruments = append(instruments, instrumentJSON{
+			Name:     name,
+			Target:   targetJSON(t),
+			BuyFee:   feeJSON(fs.Buy),
+			SellFee:  feeJSON(fs.Sell),
+			Notional: fs.Notional,
+		})
+	}
+	ruleList := make([]ruleJSON, 0, len(rules))
+	for _, rule := range rules {
+		ruleList = append(ruleList, ruleJSON(*rule))
+	}
+	sg := cfg.Strategy
+	writeJSON(w, http.StatusOK, map[string]any{
+		"interval":     st.Interval,
+		"keep_running": st.KeepRunning,
+		"notify":       notify,
+		"rearm":        map[string]any{"yuan": st.Rearm.Yuan, "minutes": st.Rearm.Minutes},
+		"instruments":  instruments,
+		"rules":        ruleList,
+		"strategy": map[string]any{
+			"instrument": sg.Instrument,
+			"kind":       sg.Kind,
+			"amount":     sg.Amount,
+			"step":       sg.Step,
+			"rise":       sg.Rise,
+			"days":       sg.Days,
+			"max_lots":   sg.MaxLots,
+			"live":       sg.Live,
+		},
+	})
+}
+
+// loadLatestQuote 数据库中最后一条报价，没有记录时返回 nil
+func loadLatestQuote(instrument string) (*PriceQuote, error) {
+	quotes, err := queryQuotes(`
+        SELECT ts, price, COALESCE(bid, price), COALESCE(ask, price),
+               COALESCE(open, 0), COALESCE(high, 0), COALESCE(low, 0), COALESCE(chg, 0)
+        FROM price_log
+        WHERE instrument = ?
+        ORDER BY ts DESC LIMIT 1
+    `, instrument)
+	if err != nil || len(quotes) == 0 {
+		return nil, err
+	}
+	return quotes[0], nil
+}
+
+// loadTicks 读取 [from, to) 内最早的 limit 条报价
+func loadTicks(instrument string, from, to time.Time, limit int) ([]*PriceQuote, error) {
+	return queryQuotes(`
+        SELECT ts, price, COALESCE(bid, price), COALESCE(ask, price),
+               COALESCE(open, 0), COALESCE(high, 0), COALESCE(low, 0), COALESCE(chg, 0)
+        FROM price_log
+        WHERE instrument = ? AND ts >= ? AND ts < ?
+        ORDER BY ts ASC LIMIT ?
+    `, instrument, from.UnixMilli(), to.UnixMilli(), limit)
+}
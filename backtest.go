@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* ---------- 回测 ---------- */
+
+const backtestDateLayout = "2006-01-02"
+
+// BacktestConfig 回测参数，提醒参数和冷却条件的含义与监控循环相同
+type BacktestConfig struct {
+	Instrument string
+	From, To   time.Time // 回放 [From, To) 内的记录
+	Target     Target
+	Rearm      RearmPolicy
+	Fees       Fees
+	MaxLots    int  // 最多持有几笔，每次买入提醒按本金买入一笔，0 表示不限
+	Rules      bool // 是否回放自定义规则，规则提醒只计次数，不模拟成交
+}
+
+// BacktestResult 回测结果，盈亏都已扣除手续费
+type BacktestResult struct {
+	Config      BacktestConfig
+	Ticks       int
+	Start, End  time.Time      // 第一条和最后一条记录的时间
+	Alerts      map[string]int // 提醒标题 -> 次数
+	Trades      []*Trade       // 模拟成交
+	Sells       int
+	Wins        int // 盈利的卖出次数
+	Holding     Holding
+	LastBid     float64
+	Unrealized  float64 // 按最后的回购价卖出全部持仓的净收益
+	MaxDrawdown float64 // 已实现加浮动盈亏从最高点回落的最大值（元）
+}
+
+// defaultBacktestConfig 最近 30 天，参数取自当前设置
+func defaultBacktestConfig(instrument string, st *MonitorSettings) BacktestConfig {
+	to := time.Now()
+	return BacktestConfig{
+		Instrument: instrument,
+		From:       to.AddDate(0, 0, -30),
+		To:         to,
+		Target:     st.Targets[instrument],
+		Rearm:      st.Rearm,
+		Fees:       feesFor(instrument),
+		MaxLots:    1,
+		Rules:      true,
+	}
+}
+
+// backtestSource 回测只用到品种名称，价格由回放提供
+type backtestSource string
+
+func (s backtestSource) Name() string { return string(s) }
+
+func (s backtestSource) Fetch(context.Context) (*PriceQuote, error) {
+	return nil, fmt.Errorf("回测不抓取价格")
+}
+
+// runBacktest 按时间顺序回放 price_log，提醒判断与监控循环相同。
+// 从空仓开始，买入提醒按买入价用本金买入一笔，卖出提醒和收益目标提醒按回购价卖出全部持仓
+func runBacktest(ctx context.Context, bc BacktestConfig) (*BacktestResult, error) {
+	t := bc.Target
+	if !bc.From.Before(bc.To) {
+		return nil, fmt.Errorf("开始时间需早于结束时间")
+	}
+	if err := t.check(); err != nil {
+		return nil, fmt.Errorf("%s %v", bc.Instrument, err)
+	}
+	if bc.Fees.Notional <= bc.Fees.Buy.Fee(bc.Fees.Notional) {
+		return nil, fmt.Errorf("每笔本金需大于买入手续费")
+	}
+	// 成本只取模拟成交，不按买入均价估算
+	t.BuyPrice = 0
+
+	res := &BacktestResult{Config: bc, Alerts: map[string]int{}}
+	st := &MonitorSettings{
+		KeepRunning: true,
+		Rearm:       bc.Rearm,
+		Targets:     map[string]Target{bc.Instrument: t},
+	}
+	ins := &monitoredInstrument{source: backtestSource(bc.Instrument)}
+	var now time.Time
+	m := &Monitor{
+		instruments: []*monitoredInstrument{ins},
+		log:         func(string) {},
+		popup:       noopPopup{},
+		dispatcher:  newDispatcher(nil, func(string) {}),
+		rules:       newRuleSet(),
+		ledger:      newLedger(),
+		fees:        func(string) Fees { return bc.Fees },
+		clock:       func() time.Time { return now },
+		latest:      map[string]*liveQuote{},
+	}
+	if bc.Rules {
+		if err := m.rules.reload(); err != nil {
+			return nil, fmt.Errorf("加载提醒规则失败: %w", err)
+		}
+	}
+
+	// 模拟成交后重新计算持仓，监控循环按新持仓计算成本和保本价
+	var quote *PriceQuote
+	lots := 0
+	fill := func(side string, grams, price, fee float64) Holding {
+		res.Trades = append(res.Trades, &Trade{
+			ID:         int64(len(res.Trades) + 1),
+			Instrument: bc.Instrument,
+			T:          now.UnixMilli(),
+			Side:       side,
+			Grams:      grams,
+			Price:      price,
+			Fee:        fee,
+		})
+		holdings, _ := computeHoldings(res.Trades) // 只会卖出全部持仓，不会出错
+		m.ledger.mu.Lock()
+		m.ledger.holdings = holdings
+		m.ledger.mu.Unlock()
+		return holdings[bc.Instrument]
+	}
+	m.onAlert = func(name, title string) {
+		res.Alerts[title]++
+		h, _ := m.ledger.get(name)
+		switch title {
+		case "买入提醒":
+			if bc.MaxLots > 0 && lots >= bc.MaxLots {
+				return
+			}
+			fee := bc.Fees.Buy.Fee(bc.Fees.Notional)
+			fill("buy", (bc.Fees.Notional-fee)/quote.Ask, quote.Ask, fee)
+			lots++
+		case "卖出提醒", "收益目标提醒":
+			if h.Grams <= 0 {
+				return
+			}
+			amount := h.Grams * quote.Bid
+			after := fill("sell", h.Grams, quote.Bid, bc.Fees.Sell.Fee(amount))
+			lots = 0
+			res.Sells++
+			if after.Realized > h.Realized {
+				res.Wins++
+			}
+		}
+	}
+
+	// 统计只用到最长窗口内的价格，其余丢弃
+	window := int64(max(t.StatsMinutes, t.VolWindow, m.rules.maxWindow()))
+	var peak float64
+	err := replayQuotes(ctx, bc.Instrument, bc.From, bc.To, func(q *PriceQuote) {
+		now, quote = q.T, q
+		cutoff := now.Unix() - window*60
+		for len(ins.recLis) > 0 && ins.recLis[0].T < cutoff {
+			ins.recLis = ins.recLis[1:]
+		}
+		m.check(ins, st, q)
+
+		if res.Ticks == 0 {
+			res.Start = now
+		}
+		res.Ticks++
+		res.End = now
+		h, _ := m.ledger.get(bc.Instrument)
+		equity := h.Realized + bc.Fees.profit(h.Grams, h.Grams*h.AvgCost, q.Bid)
+		peak = max(peak, equity)
+		res.MaxDrawdown = max(res.MaxDrawdown, peak-equity)
+		res.Holding, res.LastBid = h, q.Bid
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Ticks == 0 {
+		return nil, fmt.Errorf("%s 在 %s 至 %s 之间没有价格记录", bc.Instrument,
+			bc.From.Format(tradeTimeLayout), bc.To.Format(tradeTimeLayout))
+	}
+	h := res.Holding
+	res.Unrealized = bc.Fees.profit(h.Grams, h.Grams*h.AvgCost, res.LastBid)
+	return res, nil
+}
+
+// replayQuotes 按时间顺序逐条回放 [from, to) 内的报价，每次读取一天，读取之间不占用数据库
+func replayQuotes(ctx context.Context, instrument string, from, to time.Time, fn func(q *PriceQuote)) error {
+	for start := from; start.Before(to); start = start.Add(24 * time.Hour) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start.Add(24 * time.Hour)
+		if end.After(to) {
+			end = to
+		}
+		quotes, err := loadQuotes(instrument, start, end)
+		if err != nil {
+			return err
+		}
+		for _, q := range quotes {
+			fn(q)
+		}
+	}
+	return nil
+}
+
+// loadQuotes 读取 [from, to) 内的报价，时间取记录时间，与监控循环判断提醒时一致
+func loadQuotes(instrument string, from, to time.Time) ([]*PriceQuote, error) {
+	return queryQuotes(`
+        SELECT ts, price, COALESCE(bid, price), COALESCE(ask, price),
+               COALESCE(open, 0), COALESCE(high, 0), COALESCE(low, 0), COALESCE(chg, 0)
+        FROM price_log
+        WHERE instrument = ? AND ts >= ? AND ts < ?
+        ORDER BY ts ASC
+    `, instrument, from.UnixMilli(), to.UnixMilli())
+}
+
+// queryQuotes 执行返回 ts、price、bid、ask、open、high、low、chg 的查询
+func queryQuotes(query string, args ...any) ([]*PriceQuote, error) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未打开")
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []*PriceQuote
+	for rows.Next() {
+		var ts int64
+		var q PriceQuote
+		if err := rows.Scan(&ts, &q.Last, &q.Bid, &q.Ask, &q.Open, &q.High, &q.Low, &q.Change); err != nil {
+			return nil, err
+		}
+		q.T = time.UnixMilli(ts)
+		quotes = append(quotes, &q)
+	}
+	return quotes, rows.Err()
+}
+
+// winRate 盈利卖出次数占比（%），没有卖出时为 0
+func (r *BacktestResult) winRate() float64 {
+	if r.Sells == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(r.Sells) * 100
+}
+
+// report 回测报告，命令行和界面共用，maxTrades 大于 0 时只列出最近的几笔成交
+func (r *BacktestResult) report(maxTrades int) string {
+	c := r.Config
+	var b strings.Builder
+	fmt.Fprintf(&b, "品种: %s\n", c.Instrument)
+	fmt.Fprintf(&b, "区间: %s 至 %s，共 %d 条报价\n", r.Start.Format(tradeTimeLayout), r.End.Format(tradeTimeLayout), r.Ticks)
+	fmt.Fprintf(&b, "目标买入价: %.2f，目标卖出价: %.2f，回撤重置: %.2f 元，冷却时间: %d 分，每笔本金: %.2f\n",
+		c.Target.TargetBuyPrice, c.Target.TargetSellPrice, c.Rearm.Yuan, c.Rearm.Minutes, c.Fees.Notional)
+
+	titles := make([]string, 0, len(r.Alerts))
+	for title := range r.Alerts {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+	b.WriteString("\n提醒次数:\n")
+	if len(titles) == 0 {
+		b.WriteString("  无\n")
+	}
+	for _, title := range titles {
+		fmt.Fprintf(&b, "  %s: %d\n", title, r.Alerts[title])
+	}
+
+	writeTrades(&b, r.Trades, maxTrades)
+
+	h := r.Holding
+	fmt.Fprintf(&b, "\n卖出 %d 次，盈利 %d 次，胜率 %.1f%%\n", r.Sells, r.Wins, r.winRate())
+	fmt.Fprintf(&b, "已实现盈亏: %.2f\n", h.Realized)
+	if h.Grams > 0 {
+		fmt.Fprintf(&b, "期末持仓: %.4f 克，均价 %.2f，回购价 %.2f，浮动盈亏 %.2f\n", h.Grams, h.AvgCost, r.LastBid, r.Unrealized)
+	}
+	fmt.Fprintf(&b, "最终盈亏: %.2f，累计手续费: %.2f\n", h.Realized+r.Unrealized, h.Fees)
+	fmt.Fprintf(&b, "最大回撤: %.2f\n", r.MaxDrawdown)
+	return b.String()
+}
+
+// writeTrades 列出模拟成交，maxTrades 大于 0 时只列出最近的几笔
+func writeTrades(b *strings.Builder, trades []*Trade, maxTrades int) {
+	b.WriteString("\n模拟成交:\n")
+	if len(trades) == 0 {
+		b.WriteString("  无\n")
+	}
+	if maxTrades > 0 && len(trades) > maxTrades {
+		fmt.Fprintf(b, "  共 %d 笔，只列出最近 %d 笔\n", len(trades), maxTrades)
+		trades = trades[len(trades)-maxTrades:]
+	}
+	for _, t := range trades {
+		fmt.Fprintf(b, "  %s %s %.4f 克 @ %.2f，手续费 %.2f\n",
+			time.UnixMilli(t.T).Format(tradeTimeLayout), tradeSides[t.Side], t.Grams, t.Price, t.Fee)
+	}
+}
+
+// parseBacktestDate 解析日期，end 为 true 时返回次日零点，使结束日期当天也包含在内
+func parseBacktestDate(s string, end bool) (time.Time, error) {
+	d, err := time.ParseInLocation(backtestDateLayout, strings.TrimSpace(s), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("日期格式为 %s", backtestDateLayout)
+	}
+	if end {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d, nil
+}
+
+// runBacktestCommand 命令行回测，如 gold backtest -instrument 工行积存金 -from 2024-01-01 -to 2024-06-30
+func runBacktestCommand(args []string) error {
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	instrument := fs.String("instrument", cfg.Instruments[0], "品种")
+	from := fs.String("from", "", "开始日期，默认结束日期前 30 天")
+	to := fs.String("to", "", "结束日期（含当天），默认到现在")
+	var t Target
+	var rearm RearmPolicy
+	var fees Fees
+	var lots int
+	var rules bool
+	fs.Float64Var(&t.TargetBuyPrice, "target-buy", 0, "目标买入价格")
+	fs.Float64Var(&t.TargetSellPrice, "target-sell", 0, "目标卖出价格")
+	fs.IntVar(&t.StatsMinutes, "stats", 0, "统计时间（分）")
+	fs.Float64Var(&t.DropPct, "drop-pct", 0, "跌幅提醒（%）")
+	fs.Float64Var(&t.RisePct, "rise-pct", 0, "涨幅提醒（%）")
+	fs.Float64Var(&t.StdMult, "std-mult", 0, "偏离均值几倍标准差提醒")
+	fs.IntVar(&t.VolWindow, "vol-window", 0, "波动窗口（分）")
+	fs.Float64Var(&t.ProfitYuan, "profit-yuan", 0, "收益目标（元）")
+	fs.Float64Var(&t.ProfitPct, "profit-pct", 0, "收益目标（%）")
+	fs.Float64Var(&rearm.Yuan, "rearm-yuan", 0, "价格回到目标价之外多少元后再次提醒")
+	fs.IntVar(&rearm.Minutes, "rearm-minutes", 0, "距上次提醒多少分钟后再次提醒")
+	fs.Float64Var(&fees.Buy.Pct, "buy-pct", 0, "买入手续费（%）")
+	fs.Float64Var(&fees.Buy.Fixed, "buy-fixed", 0, "买入每笔固定手续费（元）")
+	fs.Float64Var(&fees.Buy.Min, "buy-min", 0, "买入最低手续费（元）")
+	fs.Float64Var(&fees.Sell.Pct, "sell-pct", 0, "卖出手续费（%）")
+	fs.Float64Var(&fees.Sell.Fixed, "sell-fixed", 0, "卖出每笔固定手续费（元）")
+	fs.Float64Var(&fees.Sell.Min, "sell-min", 0, "卖出最低手续费（元）")
+	fs.Float64Var(&fees.Notional, "notional", 0, "每笔买入本金（元）")
+	fs.IntVar(&lots, "lots", 1, "最多持有几笔，0 表示不限")
+	fs.BoolVar(&rules, "rules", true, "回放自定义规则")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	// 未指定的参数取配置文件
+	bc := defaultBacktestConfig(*instrument, &MonitorSettings{Rearm: cfg.Rearm, Targets: cfg.Targets})
+	bc.MaxLots, bc.Rules = lots, rules
+	var err error
+	if *to != "" {
+		if bc.To, err = parseBacktestDate(*to, true); err != nil {
+			return err
+		}
+	}
+	bc.From = bc.To.AddDate(0, 0, -30)
+	if *from != "" {
+		if bc.From, err = parseBacktestDate(*from, false); err != nil {
+			return err
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "target-buy":
+			bc.Target.TargetBuyPrice = t.TargetBuyPrice
+		case "target-sell":
+			bc.Target.TargetSellPrice = t.TargetSellPrice
+		case "stats":
+			bc.Target.StatsMinutes = t.StatsMinutes
+		case "drop-pct":
+			bc.Target.DropPct = t.DropPct
+		case "rise-pct":
+			bc.Target.RisePct = t.RisePct
+		case "std-mult":
+			bc.Target.StdMult = t.StdMult
+		case "vol-window":
+			bc.Target.VolWindow = t.VolWindow
+		case "profit-yuan":
+			bc.Target.ProfitYuan = t.ProfitYuan
+		case "profit-pct":
+			bc.Target.ProfitPct = t.ProfitPct
+		case "rearm-yuan":
+			bc.Rearm.Yuan = rearm.Yuan
+		case "rearm-minutes":
+			bc.Rearm.Minutes = rearm.Minutes
+		case "buy-pct":
+			bc.Fees.Buy.Pct = fees.Buy.Pct
+		case "buy-fixed":
+			bc.Fees.Buy.Fixed = fees.Buy.Fixed
+		case "buy-min":
+			bc.Fees.Buy.Min = fees.Buy.Min
+		case "sell-pct":
+			bc.Fees.Sell.Pct = fees.Sell.Pct
+		case "sell-fixed":
+			bc.Fees.Sell.Fixed = fees.Sell.Fixed
+		case "sell-min":
+			bc.Fees.Sell.Min = fees.Sell.Min
+		case "notional":
+			bc.Fees.Notional = fees.Notional
+		}
+	})
+
+	res, err := runBacktest(context.Background(), bc)
+	if err != nil {
+		return err
+	}
+	fmt.Print(res.report(0))
+	return nil
+}
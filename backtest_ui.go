@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+/* ---------- 回测界面 ---------- */
+
+// uiMaxTrades 界面中最多列出的成交笔数，太多时标签排版很慢
+const uiMaxTrades = 200
+
+// showBacktestWindow 参数默认取主界面当前的设置，手续费未修改的部分取配置
+func showBacktestWindow(a fyne.App, settings func() (*MonitorSettings, error)) {
+	w := a.NewWindow("回测")
+	w.Resize(fyne.NewSize(640, 600))
+	ctx, cancel := context.WithCancel(context.Background())
+	w.SetOnClosed(cancel)
+
+	st, err := settings()
+	if err != nil {
+		st = &MonitorSettings{Rearm: cfg.Rearm, Targets: cfg.Targets}
+	}
+
+	fromEntry := widget.NewEntry()
+	toEntry := widget.NewEntry()
+	targetBuyEntry := widget.NewEntry()
+	targetSellEntry := widget.NewEntry()
+	profitYuanEntry := widget.NewEntry()
+	profitPctEntry := widget.NewEntry()
+	rearmYuanEntry := widget.NewEntry()
+	rearmMinutesEntry := widget.NewEntry()
+	buyPctEntry := widget.NewEntry()
+	sellPctEntry := widget.NewEntry()
+	sellFixedEntry := widget.NewEntry()
+	notionalEntry := widget.NewEntry()
+	lotsEntry := widget.NewEntry()
+	rulesCheck := widget.NewCheck("回放自定义规则（只统计提醒次数）", nil)
+
+	date := func(s string) error {
+		_, err := parseBacktestDate(s, false)
+		return err
+	}
+	fromEntry.Validator = date
+	toEntry.Validator = date
+	targetBuyEntry.Validator = positive(true, false)
+	targetSellEntry.Validator = all(positive(false, false), targetOrder(targetBuyEntry, targetSellEntry), sellOrProfit(profitYuanEntry, profitPctEntry))
+	profitYuanEntry.Validator = nonNegative(false)
+	profitPctEntry.Validator = nonNegative(false)
+	rearmYuanEntry.Validator = nonNegative(false)
+	rearmMinutesEntry.Validator = nonNegative(true)
+	buyPctEntry.Validator = nonNegative(false)
+	sellPctEntry.Validator = nonNegative(false)
+	sellFixedEntry.Validator = nonNegative(false)
+	notionalEntry.Validator = positive(true, false)
+	lotsEntry.Validator = nonNegative(true)
+	lotsEntry.SetPlaceHolder("0 表示不限")
+
+	num := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	var bc BacktestConfig
+	// fill 切换品种时重新填入该品种的参数
+	fill := func(name string) {
+		bc = defaultBacktestConfig(name, st)
+		t := bc.Target
+		fromEntry.SetText(bc.From.Format(backtestDateLayout))
+		toEntry.SetText(bc.To.Format(backtestDateLayout))
+		targetBuyEntry.SetText(num(t.TargetBuyPrice))
+		targetSellEntry.SetText(num(t.TargetSellPrice))
+		profitYuanEntry.SetText(num(t.ProfitYuan))
+		profitPctEntry.SetText(num(t.ProfitPct))
+		rearmYuanEntry.SetText(num(bc.Rearm.Yuan))
+		rearmMinutesEntry.SetText(strconv.Itoa(bc.Rearm.Minutes))
+		buyPctEntry.SetText(num(bc.Fees.Buy.Pct))
+		sellPctEntry.SetText(num(bc.Fees.Sell.Pct))
+		sellFixedEntry.SetText(num(bc.Fees.Sell.Fixed))
+		notionalEntry.SetText(num(bc.Fees.Notional))
+		lotsEntry.SetText(strconv.Itoa(bc.MaxLots))
+		rulesCheck.SetChecked(bc.Rules)
+	}
+	instrumentSelect := widget.NewSelect(cfg.Instruments, fill)
+	instrumentSelect.SetSelected(cfg.Instruments[0])
+
+	result := widget.NewLabel("设置参数后点击开始回测")
+	result.TextStyle = fyne.TextStyle{Monospace: true}
+
+	form := widget.NewForm(
+		widget.NewFormItem("品种", instrumentSelect),
+		widget.NewFormItem("开始日期", fromEntry),
+		widget.NewFormItem("结束日期（含）", toEntry),
+		widget.NewFormItem("目标买入价", targetBuyEntry),
+		widget.NewFormItem("目标卖出价", targetSellEntry),
+		widget.NewFormItem("收益目标（元）", profitYuanEntry),
+		widget.NewFormItem("收益目标（%）", profitPctEntry),
+		widget.NewFormItem("回撤重置（元）", rearmYuanEntry),
+		widget.NewFormItem("冷却时间（分）", rearmMinutesEntry),
+		widget.NewFormItem("买入费率（%）", buyPctEntry),
+		widget.NewFormItem("卖出费率（%）", sellPctEntry),
+		widget.NewFormItem("卖出固定费用（元）", sellFixedEntry),
+		widget.NewFormItem("每笔本金（元）", notionalEntry),
+		widget.NewFormItem("最多持有笔数", lotsEntry),
+		widget.NewFormItem("", rulesCheck),
+	)
+	form.SubmitText = "开始回测"
+	form.OnSubmit = func() {
+		// 表单中没有的参数（统计时间、波动提醒、最低手续费等）沿用 fill 取到的设置
+		run := bc
+		run.From, _ = parseBacktestDate(fromEntry.Text, false)
+		run.To, _ = parseBacktestDate(toEntry.Text, true)
+		f := func(e *widget.Entry) float64 {
+			v, _ := parseNumber(e.Text, false, false)
+			return v
+		}
+		run.Target.TargetBuyPrice = f(targetBuyEntry)
+		run.Target.TargetSellPrice = f(targetSellEntry)
+		run.Target.ProfitYuan = f(profitYuanEntry)
+		run.Target.ProfitPct = f(profitPctEntry)
+		run.Rearm.Yuan = f(rearmYuanEntry)
+		run.Rearm.Minutes, _ = strconv.Atoi(strings.TrimSpace(rearmMinutesEntry.Text))
+		run.Fees.Buy.Pct = f(buyPctEntry)
+		run.Fees.Sell.Pct = f(sellPctEntry)
+		run.Fees.Sell.Fixed = f(sellFixedEntry)
+		run.Fees.Notional = f(notionalEntry)
+		run.MaxLots, _ = strconv.Atoi(strings.TrimSpace(lotsEntry.Text))
+		run.Rules = rulesCheck.Checked
+
+		form.Disable()
+		result.SetText("回测中……")
+		go func() {
+			res, err := runBacktest(ctx, run)
+			fyne.Do(func() {
+				form.Enable()
+				if err != nil {
+					result.SetText("回测失败: " + err.Error())
+					return
+				}
+				result.SetText(res.report(uiMaxTrades))
+			})
+		}()
+	}
+
+	w.SetContent(container.NewBorder(form, nil, nil, nil, container.NewScroll(result)))
+	w.Show()
+}
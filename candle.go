@@ -0,0 +1,128 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------- K线查询 ---------- */
+
+// rawCandleRange 不超过该时长且原始记录未清理时直接由原始记录聚合，更长的区间使用汇总表
+const rawCandleRange = 48 * time.Hour
+
+// parseCandleInterval 解析 1m、5m、15m、1h、1d 这样的周期，单位为 m/h/d
+func parseCandleInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	units := map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("无效的K线周期: %q", s)
+	}
+	unit, ok := units[s[len(s)-1]]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的K线周期: %q", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// getCandles 返回 [from, to) 内按 interval 聚合的K线，周期起点按本地时区对齐
+func getCandles(instrument string, interval time.Duration, from, to time.Time) ([]*Candle, error) {
+	if interval < time.Minute || interval%time.Minute != 0 {
+		return nil, fmt.Errorf("K线周期需为整数分钟: %v", interval)
+	}
+	start := bucketStart(from.UnixMilli(), interval)
+	end := to.UnixMilli()
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未打开")
+	}
+
+	// 选能整除周期的最大汇总表
+	var level *rollupLevel
+	for i := range rollupLevels {
+		if interval%rollupLevels[i].period == 0 {
+			level = &rollupLevels[i]
+		}
+	}
+	rawCutoff := time.Now().AddDate(0, 0, -cfg.Retention.RawDays)
+	useRollup := to.Sub(from) > rawCandleRange || (cfg.Retention.RawDays > 0 && from.Before(rawCutoff))
+	if level == nil || !useRollup {
+		src, err := queryRawCandles(instrument, start, end)
+		if err != nil {
+			return nil, err
+		}
+		return mergeCandles(src, interval), nil
+	}
+
+	// 汇总表最后一个周期可能不完整，从该周期起改用原始记录
+	var watermark sql.NullInt64
+	err := db.QueryRow("SELECT MAX(ts) FROM "+level.table+" WHERE instrument = ?", instrument).Scan(&watermark)
+	if err != nil {
+		return nil, err
+	}
+	tail := start
+	var src []*Candle
+	if watermark.Valid {
+		src, err = queryRollupCandles(level.table, instrument, start, min(watermark.Int64, end))
+		if err != nil {
+			return nil, err
+		}
+		tail = max(start, watermark.Int64)
+	}
+	raw, err := queryRawCandles(instrument, tail, end)
+	if err != nil {
+		return nil, err
+	}
+	return mergeCandles(append(src, raw...), interval), nil
+}
+
+// queryRawCandles 每条原始记录作为一根K线返回
+func queryRawCandles(instrument string, from, to int64) ([]*Candle, error) {
+	rows, err := db.Query(`
+        SELECT ts, price FROM price_log
+        WHERE instrument = ? AND ts >= ? AND ts < ?
+        ORDER BY ts
+    `, instrument, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Candle
+	for rows.Next() {
+		var ts int64
+		var price float64
+		if err := rows.Scan(&ts, &price); err != nil {
+			return nil, err
+		}
+		out = append(out, &Candle{T: ts, Open: price, High: price, Low: price, Close: price, Count: 1})
+	}
+	return out, rows.Err()
+}
+
+func queryRollupCandles(table, instrument string, from, to int64) ([]*Candle, error) {
+	rows, err := db.Query(`
+        SELECT ts, open, high, low, close, count FROM `+table+`
+        WHERE instrument = ? AND ts >= ? AND ts < ?
+        ORDER BY ts
+    `, instrument, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Candle
+	for rows.Next() {
+		var c Candle
+		if err := rows.Scan(&c.T, &c.Open, &c.High, &c.Low, &c.Close, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, &c)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+/* ---------- 价格走势图 ---------- */
+
+// chartRange 可选的时间范围及对应的K线周期
+type chartRange struct {
+	label    string
+	span     time.Duration
+	interval time.Duration
+}
+
+var chartRanges = []chartRange{
+	{"1小时", time.Hour, time.Minute},
+	{"12小时", 12 * time.Hour, 5 * time.Minute},
+	{"1天", 24 * time.Hour, 15 * time.Minute},
+	{"1周", 7 * 24 * time.Hour, time.Hour},
+	{"1月", 30 * 24 * time.Hour, 4 * time.Hour},
+}
+
+// chartLine 图上的水平参考线
+type chartLine struct {
+	label string
+	value float64
+	color color.Color
+}
+
+var (
+	colorUp        = color.NRGBA{R: 0xe5, G: 0x39, B: 0x35, A: 0xff} // 涨为红
+	colorDown      = color.NRGBA{R: 0x43, G: 0xa0, B: 0x47, A: 0xff} // 跌为绿
+	colorBuy       = color.NRGBA{R: 0xfb, G: 0xc0, B: 0x2d, A: 0xff}
+	colorTargetBuy = color.NRGBA{R: 0x29, G: 0xb6, B: 0xf6, A: 0xff}
+	colorTargetSel = color.NRGBA{R: 0xab, G: 0x47, B: 0xbc, A: 0xff}
+	colorProfit    = color.NRGBA{R: 0xff, G: 0x70, B: 0x43, A: 0xff}
+)
+
+// priceChart K线/折线图，数据由 chartPanel 设置
+type priceChart struct {
+	widget.BaseWidget
+	mu         sync.Mutex
+	candles    []*Candle
+	lines      []chartLine
+	candleMode bool
+}
+
+func newPriceChart() *priceChart {
+	c := &priceChart{candleMode: true}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+func (c *priceChart) CreateRenderer() fyne.WidgetRenderer {
+	return &chartRenderer{chart: c}
+}
+
+func (c *priceChart) MinSize() fyne.Size {
+	return fyne.NewSize(300, 220)
+}
+
+// chartRenderer 每次布局时按当前数据重新生成图形
+type chartRenderer struct {
+	chart   *priceChart
+	objects []fyne.CanvasObject
+}
+
+func (r *chartRenderer) Destroy() {}
+
+func (r *chartRenderer) MinSize() fyne.Size { return r.chart.MinSize() }
+
+func (r *chartRenderer) Objects() []fyne.CanvasObject { return r.objects }
+
+func (r *chartRenderer) Refresh() {
+	r.Layout(r.chart.Size())
+	canvas.Refresh(r.chart)
+}
+
+func (r *chartRenderer) Layout(size fyne.Size) {
+	c := r.chart
+	c.mu.Lock()
+	candles := c.candles
+	lines := c.lines
+	candleMode := c.candleMode
+	c.mu.Unlock()
+
+	fg := theme.Color(theme.ColorNameForeground)
+	grid := theme.Color(theme.ColorNameSeparator)
+	bg := canvas.NewRectangle(theme.Color(theme.ColorNameInputBackground))
+	bg.Resize(size)
+	bg.Move(fyne.NewPos(0, 0))
+	objects := []fyne.CanvasObject{bg}
+
+	if len(candles) == 0 {
+		t := canvas.NewText("暂无数据", fg)
+		t.Move(fyne.NewPos(size.Width/2-30, size.Height/2-10))
+		r.objects = append(objects, t)
+		return
+	}
+
+	// 右侧留出价格标签，底部留出时间标签
+	const right, bottom, top = 64, 20, 6
+	w := size.Width - right
+	h := size.Height - bottom - top
+	if w <= 0 || h <= 0 {
+		r.objects = objects
+		return
+	}
+
+	lo, hi := candles[0].Low, candles[0].High
+	for _, k := range candles {
+		lo = min(lo, k.Low)
+		hi = max(hi, k.High)
+	}
+	for _, l := range lines {
+		lo = min(lo, l.value)
+		hi = max(hi, l.value)
+	}
+	pad := (hi - lo) * 0.05
+	if pad == 0 {
+		pad = 1
+	}
+	lo, hi = lo-pad, hi+pad
+	y := func(p float64) float32 {
+		return top + float32((hi-p)/(hi-lo))*h
+	}
+	step := w / float32(len(candles))
+	x := func(i int) float32 {
+		return step * (float32(i) + 0.5)
+	}
+
+	for _, k := range []float64{hi, lo} {
+		objects = append(objects, hline(0, w, y(k), grid), label(fmt.Sprintf("%.2f", k), w+4, y(k)-8, fg))
+	}
+
+	for i, k := range candles {
+		if !candleMode {
+			if i > 0 {
+				seg := canvas.NewLine(theme.Color(theme.ColorNamePrimary))
+				seg.StrokeWidth = 1.5
+				seg.Position1 = fyne.NewPos(x(i-1), y(candles[i-1].Close))
+				seg.Position2 = fyne.NewPos(x(i), y(k.Close))
+				objects = append(objects, seg)
+			}
+			continue
+		}
+		col := colorUp
+		if k.Close < k.Open {
+			col = colorDown
+		}
+		wick := canvas.NewLine(col)
+		wick.Position1 = fyne.NewPos(x(i), y(k.High))
+		wick.Position2 = fyne.NewPos(x(i), y(k.Low))
+		body := canvas.NewRectangle(col)
+		y1, y2 := y(max(k.Open, k.Close)), y(min(k.Open, k.Close))
+		bw := max(step*0.7, 1)
+		body.Move(fyne.NewPos(x(i)-bw/2, y1))
+		body.Resize(fyne.NewSize(bw, max(y2-y1, 1)))
+		objects = append(objects, wick, body)
+	}
+
+	for _, l := range lines {
+		objects = append(objects, hline(0, w, y(l.value), l.color),
+			label(fmt.Sprintf("%s %.2f", l.label, l.value), w+4, y(l.value)-8, l.color))
+	}
+
+	last := candles[len(candles)-1]
+	objects = append(objects,
+		label(time.UnixMilli(candles[0].T).Format("01-02 15:04"), 0, size.Height-bottom, fg),
+		label(time.UnixMilli(last.T).Format("01-02 15:04"), w-70, size.Height-bottom, fg),
+		label(fmt.Sprintf("%.2f", last.Close), w+4, y(last.Close)-8, theme.Color(theme.ColorNamePrimary)),
+	)
+	r.objects = objects
+}
+
+func hline(x1, x2, y float32, col color.Color) *canvas.Line {
+	l := canvas.NewLine(col)
+	l.Position1 = fyne.NewPos(x1, y)
+	l.Position2 = fyne.NewPos(x2, y)
+	return l
+}
+
+func label(text string, x, y float32, col color.Color) *canvas.Text {
+	t := canvas.NewText(text, col)
+	t.TextSize = 11
+	t.Move(fyne.NewPos(x, y))
+	return t
+}
+
+// chartPanel 走势图及品种、范围、样式选择
+type chartPanel struct {
+	chart   *priceChart
+	lines   func(name string) []chartLine // 当前买入均价与目标价
+	log     func(string)
+	content fyne.CanvasObject
+
+	mu         sync.Mutex
+	instrument string
+	rng        chartRange
+}
+
+func newChartPanel(lines func(name string) []chartLine, log func(string)) *chartPanel {
+	p := &chartPanel{
+		chart:      newPriceChart(),
+		lines:      lines,
+		log:        log,
+		instrument: cfg.Instruments[0],
+		rng:        chartRanges[1],
+	}
+
+	// 先设初始值再绑定回调，避免启动时重复查询
+	instrumentSelect := widget.NewSelect(cfg.Instruments, nil)
+	instrumentSelect.SetSelected(p.instrument)
+	instrumentSelect.OnChanged = func(s string) {
+		p.mu.Lock()
+		p.instrument = s
+		p.mu.Unlock()
+		p.reload()
+	}
+
+	var labels []string
+	for _, r := range chartRanges {
+		labels = append(labels, r.label)
+	}
+	rangeRadio := widget.NewRadioGroup(labels, nil)
+	rangeRadio.Horizontal = true
+	rangeRadio.Required = true
+	rangeRadio.SetSelected(p.rng.label)
+	rangeRadio.OnChanged = func(s string) {
+		for _, r := range chartRanges {
+			if r.label == s {
+				p.mu.Lock()
+				p.rng = r
+				p.mu.Unlock()
+			}
+		}
+		p.reload()
+	}
+
+	modeRadio := widget.NewRadioGroup([]string{"K线", "折线"}, nil)
+	modeRadio.Horizontal = true
+	modeRadio.Required = true
+	modeRadio.SetSelected("K线")
+	modeRadio.OnChanged = func(s string) {
+		p.chart.mu.Lock()
+		p.chart.candleMode = s == "K线"
+		p.chart.mu.Unlock()
+		p.chart.Refresh()
+	}
+
+	controls := container.NewHBox(instrumentSelect, rangeRadio, modeRadio)
+	p.content = container.NewBorder(controls, nil, nil, nil, p.chart)
+	p.reload()
+	return p
+}
+
+// reload 按当前品种和范围从数据库重新读取K线
+func (p *chartPanel) reload() {
+	p.mu.Lock()
+	name, rng := p.instrument, p.rng
+	p.mu.Unlock()
+
+	go func() {
+		now := time.Now()
+		candles, err := getCandles(name, rng.interval, now.Add(-rng.span), now)
+		if err != nil {
+			p.log(fmt.Sprintf("读取走势图数据失败: %v", err))
+		}
+		p.mu.Lock()
+		stale := name != p.instrument || rng != p.rng
+		p.mu.Unlock()
+		if stale {
+			return
+		}
+		fyne.Do(func() {
+			p.chart.mu.Lock()
+			p.chart.candles = candles
+			p.chart.lines = p.lines(name)
+			p.chart.mu.Unlock()
+			p.chart.Refresh()
+		})
+	}()
+}
+
+// onQuote 每次取到价格时更新最后一根K线，不重新查询数据库
+func (p *chartPanel) onQuote(name string, quote *PriceQuote) {
+	p.mu.Lock()
+	instrument, rng := p.instrument, p.rng
+	p.mu.Unlock()
+	if name != instrument {
+		return
+	}
+
+	now := time.Now()
+	price := quote.Last
+	fyne.Do(func() {
+		c := p.chart
+		c.mu.Lock()
+		start := bucketStart(now.UnixMilli(), rng.interval)
+		if n := len(c.candles); n > 0 && c.candles[n-1].T == start {
+			k := c.candles[n-1]
+			k.High = max(k.High, price)
+			k.Low = min(k.Low, price)
+			k.Close = price
+			k.Count++
+		} else {
+			c.candles = append(c.candles, &Candle{T: start, Open: price, High: price, Low: price, Close: price, Count: 1})
+		}
+		// 去掉超出范围的K线
+		cutoff := now.Add(-rng.span).UnixMilli()
+		for len(c.candles) > 0 && c.candles[0].T+rng.interval.Milliseconds() <= cutoff {
+			c.candles = c.candles[1:]
+		}
+		c.lines = p.lines(name)
+		c.mu.Unlock()
+		c.Refresh()
+	})
+}
+
+// refreshLines 输入框修改后重画参考线
+func (p *chartPanel) refreshLines() {
+	p.mu.Lock()
+	name := p.instrument
+	p.mu.Unlock()
+	p.chart.mu.Lock()
+	p.chart.lines = p.lines(name)
+	p.chart.mu.Unlock()
+	p.chart.Refresh()
+}
@@ -0,0 +1,109 @@
+package main
+
+import "fmt"
+
+/* ---------- 手续费 ---------- */
+
+// FeeModel 单边手续费：成交金额的百分比加固定费用，不低于最低收费
+type FeeModel struct {
+	Pct   float64 // 百分比，如 0.5 表示 0.5%
+	Fixed float64 // 每笔固定费用（元）
+	Min   float64 // 最低收费（元）
+}
+
+// Fee 成交金额对应的手续费
+func (f FeeModel) Fee(amount float64) float64 {
+	return max(amount*f.Pct/100+f.Fixed, f.Min)
+}
+
+// grossUp 扣除手续费后到手 net 元所需的成交金额
+func (f FeeModel) grossUp(net float64) float64 {
+	amount := (net + f.Fixed) / (1 - f.Pct/100)
+	if amount*f.Pct/100+f.Fixed < f.Min {
+		amount = net + f.Min
+	}
+	return amount
+}
+
+// check 百分比需在 [0, 100) 内，否则保本价无意义；固定费用和最低收费不能为负
+func (f FeeModel) check() error {
+	if f.Pct < 0 || f.Pct >= 100 {
+		return fmt.Errorf("百分比 %v 需不小于 0 且小于 100", f.Pct)
+	}
+	if f.Fixed < 0 {
+		return fmt.Errorf("固定费用 %v 不能为负", f.Fixed)
+	}
+	if f.Min < 0 {
+		return fmt.Errorf("最低收费 %v 不能为负", f.Min)
+	}
+	return nil
+}
+
+// Fees 一个品种的买卖手续费，Notional 为没有持仓记录时估算收益用的本金
+type Fees struct {
+	Buy      FeeModel
+	Sell     FeeModel
+	Notional float64
+}
+
+// check 买卖两边分别检查
+func (fs Fees) check() error {
+	if err := fs.Buy.check(); err != nil {
+		return fmt.Errorf("买入%w", err)
+	}
+	if err := fs.Sell.check(); err != nil {
+		return fmt.Errorf("卖出%w", err)
+	}
+	return nil
+}
+
+// defaultFees 与原先的万元本金、卖出 50 元手续费一致
+var defaultFees = Fees{Sell: FeeModel{Fixed: 50}, Notional: 10000}
+
+func feesFor(instrument string) Fees {
+	if f, ok := cfg.Fees[instrument]; ok {
+		return f
+	}
+	return defaultFees
+}
+
+// position 计算收益用的克数和成本：有持仓时取账本，否则按本金和买入均价估算
+func (fs Fees) position(buyPrice float64, h Holding) (grams, cost float64) {
+	if h.Grams > 0 {
+		return h.Grams, h.Grams * h.AvgCost
+	}
+	return fs.estimate(buyPrice)
+}
+
+// estimate 以本金按买入均价买入（本金含买入手续费）得到的克数和成本
+func (fs Fees) estimate(buyPrice float64) (grams, cost float64) {
+	if buyPrice <= 0 {
+		return 0, 0
+	}
+	return (fs.Notional - fs.Buy.Fee(fs.Notional)) / buyPrice, fs.Notional
+}
+
+// profit 按回购价全部卖出、扣除卖出手续费后的净收益
+func (fs Fees) profit(grams, cost, bid float64) float64 {
+	if grams <= 0 {
+		return 0
+	}
+	amount := grams * bid
+	return amount - fs.Sell.Fee(amount) - cost
+}
+
+// breakEven 扣除卖出手续费后不亏损的最低回购价
+func (fs Fees) breakEven(grams, cost float64) float64 {
+	if grams <= 0 {
+		return 0
+	}
+	return fs.Sell.grossUp(cost) / grams
+}
+
+// targetPrice 扣除卖出手续费后净收益达到 goal 元所需的回购价
+func (fs Fees) targetPrice(grams, cost, goal float64) float64 {
+	if grams <= 0 {
+		return 0
+	}
+	return fs.Sell.grossUp(cost+goal) / grams
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestFeeModel(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		model  FeeModel
+		amount float64
+		fee    float64
+	}{
+		{"百分比", FeeModel{Pct: 0.5}, 10000, 50},
+		{"百分比加固定", FeeModel{Pct: 0.5, Fixed: 10}, 10000, 60},
+		{"低于最低收费", FeeModel{Pct: 0.5, Min: 30}, 1000, 30},
+		{"恰好最低收费", FeeModel{Pct: 0.5, Min: 30}, 6000, 30},
+		{"高于最低收费", FeeModel{Pct: 0.5, Min: 30}, 10000, 50},
+		{"只有固定费用", FeeModel{Fixed: 50}, 10000, 50},
+		{"免费", FeeModel{}, 10000, 0},
+	} {
+		if got := c.model.Fee(c.amount); !near(got, c.fee) {
+			t.Errorf("%s: Fee(%v) = %v, want %v", c.name, c.amount, got, c.fee)
+		}
+	}
+}
+
+// grossUp 的成交金额扣除手续费后恰好到手 net 元
+func TestFeeGrossUp(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		model  FeeModel
+		net    float64
+		amount float64
+	}{
+		{"百分比", FeeModel{Pct: 0.5}, 9950, 10000},
+		{"固定费用", FeeModel{Fixed: 50}, 10000, 10050},
+		{"最低收费", FeeModel{Pct: 0.5, Min: 30}, 1000, 1030},
+		{"最低收费边界", FeeModel{Pct: 0.5, Min: 30}, 5970, 6000},
+		{"高于最低收费", FeeModel{Pct: 0.5, Min: 30}, 9950, 10000},
+		{"百分比加固定", FeeModel{Pct: 1, Fixed: 10}, 9890, 10000},
+	} {
+		got := c.model.grossUp(c.net)
+		if !near(got, c.amount) {
+			t.Errorf("%s: grossUp(%v) = %v, want %v", c.name, c.net, got, c.amount)
+		}
+		if net := got - c.model.Fee(got); !near(net, c.net) {
+			t.Errorf("%s: 到手 %v, want %v", c.name, net, c.net)
+		}
+	}
+}
+
+func TestFeesBreakEvenAndTarget(t *testing.T) {
+	fixed := Fees{Sell: FeeModel{Fixed: 50}, Notional: 10000}
+	pct := Fees{Buy: FeeModel{Pct: 0.1}, Sell: FeeModel{Pct: 0.5, Min: 30}, Notional: 10000}
+	for _, c := range []struct {
+		name         string
+		fees         Fees
+		grams, cost  float64
+		breakEven    float64
+		goal, target float64
+	}{
+		{"固定卖出费", fixed, 20, 10000, 502.5, 100, 507.5},
+		{"百分比卖出费", pct, 20, 9950, 500, 99.5, 505},
+		{"最低卖出费", pct, 2, 1000, 515, 100, 565},
+		{"没有持仓", fixed, 0, 0, 0, 100, 0},
+	} {
+		if got := c.fees.breakEven(c.grams, c.cost); !near(got, c.breakEven) {
+			t.Errorf("%s: breakEven = %v, want %v", c.name, got, c.breakEven)
+		}
+		if got := c.fees.targetPrice(c.grams, c.cost, c.goal); !near(got, c.target) {
+			t.Errorf("%s: targetPrice = %v, want %v", c.name, got, c.target)
+		}
+		// 按保本价卖出净收益为 0，按目标价卖出净收益为 goal
+		if c.grams > 0 {
+			if got := c.fees.profit(c.grams, c.cost, c.breakEven); !near(got, 0) {
+				t.Errorf("%s: profit at break-even = %v", c.name, got)
+			}
+			if got := c.fees.profit(c.grams, c.cost, c.target); !near(got, c.goal) {
+				t.Errorf("%s: profit at target = %v, want %v", c.name, got, c.goal)
+			}
+		}
+	}
+}
+
+func TestFeesPosition(t *testing.T) {
+	fees := Fees{Buy: FeeModel{Pct: 0.1}, Notional: 10000}
+	// 没有持仓时按本金扣除买入手续费估算
+	if grams, cost := fees.position(500, Holding{}); !near(grams, 19.98) || cost != 10000 {
+		t.Errorf("estimate = %v g, %v", grams, cost)
+	}
+	if grams, cost := fees.position(0, Holding{}); grams != 0 || cost != 0 {
+		t.Errorf("no buy price = %v g, %v", grams, cost)
+	}
+	// 有持仓时取账本
+	if grams, cost := fees.position(500, Holding{Grams: 10, AvgCost: 480}); grams != 10 || cost != 4800 {
+		t.Errorf("holding = %v g, %v", grams, cost)
+	}
+}
+
+// 元和百分比都设置时取先达到的较低价格
+func TestProfitTarget(t *testing.T) {
+	fees := Fees{Sell: FeeModel{Fixed: 50}}
+	for _, c := range []struct {
+		target Target
+		want   float64
+	}{
+		{Target{}, 0},
+		{Target{ProfitYuan: 100}, 507.5},
+		{Target{ProfitPct: 2}, 512.5},
+		{Target{ProfitYuan: 300, ProfitPct: 2}, 512.5},
+		{Target{ProfitYuan: 100, ProfitPct: 2}, 507.5},
+	} {
+		if got := c.target.profitTarget(20, 10000, fees); !near(got, c.want) {
+			t.Errorf("%+v: got %v, want %v", c.target, got, c.want)
+		}
+	}
+}
+
+// 卖出百分比为 100 时 grossUp 除以零，配置中需拒绝
+func TestFeesCheck(t *testing.T) {
+	for _, c := range []struct {
+		fees Fees
+		ok   bool
+	}{
+		{defaultFees, true},
+		{Fees{Buy: FeeModel{Pct: 0.5, Min: 1}, Sell: FeeModel{Pct: 99.9}}, true},
+		{Fees{Sell: FeeModel{Pct: 100}}, false},
+		{Fees{Sell: FeeModel{Pct: -1}}, false},
+		{Fees{Buy: FeeModel{Pct: 100}}, false},
+		{Fees{Buy: FeeModel{Fixed: -1}}, false},
+		{Fees{Sell: FeeModel{Min: -1}}, false},
+	} {
+		if err := c.fees.check(); (err == nil) != c.ok {
+			t.Errorf("%+v: got %v, want ok=%v", c.fees, err, c.ok)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/* ---------- 后台模式 ---------- */
+
+// runHeadless 无界面运行，参数来自 conf.ini 与命令行，收到 SIGINT/SIGTERM 后退出
+func runHeadless(sources []PriceSource) error {
+	if cfg.Interval < minIntervalSeconds || cfg.Interval > maxIntervalSeconds {
+		return fmt.Errorf("间隔时间无效: %d，需在 %d 到 %d 秒之间", cfg.Interval, minIntervalSeconds, maxIntervalSeconds)
+	}
+	trades, err := loadTrades()
+	if err != nil {
+		return err
+	}
+	holdings, err := computeHoldings(trades)
+	if err != nil {
+		return err
+	}
+	for _, src := range sources {
+		t := cfg.Targets[src.Name()]
+		// 有持仓记录时买入均价取自账本
+		if t.BuyPrice <= 0 && holdings[src.Name()].Grams <= 0 {
+			return fmt.Errorf("%s 未配置 buy_price，且持仓账本中没有持仓", src.Name())
+		}
+		// 目标卖出价可以不配置，此时按 profit_yuan/profit_pct 提醒卖出
+		if err := t.check(); err != nil {
+			return fmt.Errorf("%s %v", src.Name(), err)
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("打开日志文件失败: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	var logMutex sync.Mutex
+	log := func(msg string) {
+		logMutex.Lock()
+		defer logMutex.Unlock()
+		fmt.Fprintf(out, "%s: %s\n", time.Now().Format("2006-01-02 15:04:05"), msg)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	settings := configSettings()
+	monitor := newMonitor(sources, func() (*MonitorSettings, error) {
+		return settings, nil
+	}, log)
+
+	log(fmt.Sprintf("后台模式已启动，间隔:%d秒，持续监控:%v，启用通知:%v，通知渠道:%s", cfg.Interval, cfg.KeepRunning, notify, monitor.dispatcher.names()))
+	monitor.dispatcher.ResendQueued()
+	startMaintenance(ctx, log)
+	startHTTPServer(ctx, monitor, log)
+	monitor.Run(ctx)
+	if ctx.Err() != nil {
+		log("收到退出信号，已停止")
+	} else {
+		log("监控已停止")
+	}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if insertStmt != nil {
+		insertStmt.Close()
+		insertStmt = nil // 尚未完成的异步写入将被忽略
+	}
+	err = db.Close()
+	db = nil // 数据整理任务随后退出
+	return err
+}
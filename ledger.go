@@ -0,0 +1,186 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/* ---------- 持仓账本 ---------- */
+
+// Trade 一笔买入或卖出
+type Trade struct {
+	ID         int64
+	Instrument string
+	T          int64  // 成交时间（毫秒）
+	Side       string // buy/sell
+	Grams      float64
+	Price      float64 // 成交单价（元/克）
+	Fee        float64 // 手续费（元）
+}
+
+// Holding 按加权平均成本法计算的持仓
+type Holding struct {
+	Grams    float64
+	AvgCost  float64 // 每克成本，含买入手续费
+	Realized float64 // 已实现盈亏，已扣卖出手续费
+	Fees     float64 // 累计手续费
+}
+
+// gramsEpsilon 克数比较的误差，避免浮点误差导致全部卖出后剩余极小持仓
+const gramsEpsilon = 1e-6
+
+// computeHoldings 按时间顺序累计各品种持仓，卖出超过持仓时返回错误
+func computeHoldings(trades []*Trade) (map[string]Holding, error) {
+	sorted := append([]*Trade(nil), trades...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].T != sorted[j].T {
+			return sorted[i].T < sorted[j].T
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	holdings := map[string]Holding{}
+	for _, t := range sorted {
+		h, err := holdings[t.Instrument].apply(t)
+		if err != nil {
+			return nil, err
+		}
+		holdings[t.Instrument] = h
+	}
+	return holdings, nil
+}
+
+// apply 计入一笔交易后的持仓
+func (h Holding) apply(t *Trade) (Holding, error) {
+	h.Fees += t.Fee
+	switch t.Side {
+	case "buy":
+		cost := h.Grams*h.AvgCost + t.Grams*t.Price + t.Fee
+		h.Grams += t.Grams
+		h.AvgCost = cost / h.Grams
+	case "sell":
+		if t.Grams > h.Grams+gramsEpsilon {
+			return h, fmt.Errorf("%s 卖出 %.4f 克超过持仓 %.4f 克", t.Instrument, t.Grams, h.Grams)
+		}
+		h.Realized += t.Grams*(t.Price-h.AvgCost) - t.Fee
+		h.Grams -= t.Grams
+		if h.Grams < gramsEpsilon {
+			h.Grams, h.AvgCost = 0, 0
+		}
+	default:
+		return h, fmt.Errorf("未知的交易方向: %s", t.Side)
+	}
+	return h, nil
+}
+
+// ledger 当前持仓，交易修改后 reload
+type ledger struct {
+	mu       sync.Mutex
+	holdings map[string]Holding
+}
+
+func newLedger() *ledger {
+	return &ledger{holdings: map[string]Holding{}}
+}
+
+func (l *ledger) reload() error {
+	trades, err := loadTrades()
+	if err != nil {
+		return err
+	}
+	holdings, err := computeHoldings(trades)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.holdings = holdings
+	l.mu.Unlock()
+	return nil
+}
+
+// get 返回品种的持仓，没有交易记录时 ok 为 false
+func (l *ledger) get(instrument string) (Holding, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.holdings[instrument]
+	return h, ok
+}
+
+/* ---------- 交易存储 ---------- */
+
+func loadTrades() ([]*Trade, error) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(`
+        SELECT id, instrument, ts, side, grams, price, fee
+        FROM trade
+        ORDER BY ts ASC, id ASC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []*Trade
+	for rows.Next() {
+		var t Trade
+		if err := rows.Scan(&t.ID, &t.Instrument, &t.T, &t.Side, &t.Grams, &t.Price, &t.Fee); err != nil {
+			return nil, err
+		}
+		trades = append(trades, &t)
+	}
+	return trades, rows.Err()
+}
+
+// saveTrade ID 为 0 时新增，否则更新
+func saveTrade(t *Trade) error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if t.ID == 0 {
+		res, err := db.Exec(`
+            INSERT INTO trade(instrument, ts, side, grams, price, fee)
+            VALUES(?, ?, ?, ?, ?, ?)
+        `, t.Instrument, t.T, t.Side, t.Grams, t.Price, t.Fee)
+		if err != nil {
+			return err
+		}
+		t.ID, err = res.LastInsertId()
+		return err
+	}
+	_, err := db.Exec(`
+        UPDATE trade SET instrument = ?, ts = ?, side = ?, grams = ?, price = ?, fee = ?
+        WHERE id = ?
+    `, t.Instrument, t.T, t.Side, t.Grams, t.Price, t.Fee, t.ID)
+	return err
+}
+
+func deleteTrade(id int64) error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	_, err := db.Exec(`DELETE FROM trade WHERE id = ?`, id)
+	return err
+}
+
+// latestBid 数据库中最近一次的回购价，用于未运行监控时计算浮动盈亏
+func latestBid(instrument string) (float64, bool) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return 0, false
+	}
+	var bid sql.NullFloat64
+	err := db.QueryRow(`
+        SELECT COALESCE(bid, price) FROM price_log
+        WHERE instrument = ?
+        ORDER BY ts DESC LIMIT 1
+    `, instrument).Scan(&bid)
+	if err != nil || !bid.Valid {
+		return 0, false
+	}
+	return bid.Float64, true
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func buy(id, t int64, grams, price, fee float64) *Trade {
+	return &Trade{ID: id, Instrument: "工行积存金", T: t, Side: "buy", Grams: grams, Price: price, Fee: fee}
+}
+
+func sell(id, t int64, grams, price, fee float64) *Trade {
+	return &Trade{ID: id, Instrument: "工行积存金", T: t, Side: "sell", Grams: grams, Price: price, Fee: fee}
+}
+
+func TestComputeHoldings(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		trades []*Trade
+		want   Holding
+	}{
+		{"买入计入手续费", []*Trade{buy(1, 1, 10, 500, 10)},
+			Holding{Grams: 10, AvgCost: 501, Fees: 10}},
+		{"加权平均成本", []*Trade{buy(1, 1, 10, 500, 10), buy(2, 2, 10, 520, 10)},
+			Holding{Grams: 20, AvgCost: 511, Fees: 20}},
+		// 部分卖出不改变平均成本，之后买入按剩余持仓加权
+		{"部分卖出", []*Trade{buy(1, 1, 10, 500, 10), buy(2, 2, 10, 520, 10), sell(3, 3, 5, 530, 5)},
+			Holding{Grams: 15, AvgCost: 511, Realized: 90, Fees: 25}},
+		{"部分卖出后买入", []*Trade{buy(1, 1, 10, 500, 10), buy(2, 2, 10, 520, 10), sell(3, 3, 5, 530, 5), buy(4, 4, 5, 490, 0)},
+			Holding{Grams: 20, AvgCost: 505.75, Realized: 90, Fees: 25}},
+		{"亏损卖出", []*Trade{buy(1, 1, 10, 500, 0), sell(2, 2, 4, 490, 2)},
+			Holding{Grams: 6, AvgCost: 500, Realized: -42, Fees: 2}},
+		// 清仓后成本归零，再买入重新计算
+		{"清仓", []*Trade{buy(1, 1, 10, 500, 0), sell(2, 2, 10, 510, 10)},
+			Holding{Realized: 90, Fees: 10}},
+		{"清仓后买入", []*Trade{buy(1, 1, 10, 500, 0), sell(2, 2, 10, 510, 10), buy(3, 3, 2, 480, 0)},
+			Holding{Grams: 2, AvgCost: 480, Realized: 90, Fees: 10}},
+		{"浮点误差内清仓", []*Trade{buy(1, 1, 0.1, 500, 0), buy(2, 2, 0.2, 500, 0), sell(3, 3, 0.3, 500, 0)},
+			Holding{}},
+		// 按时间排序，时间相同时按 ID
+		{"按时间排序", []*Trade{sell(2, 2, 10, 510, 0), buy(1, 1, 10, 500, 0)},
+			Holding{Realized: 100}},
+		{"同一时间按 ID", []*Trade{sell(2, 1, 10, 510, 0), buy(1, 1, 10, 500, 0)},
+			Holding{Realized: 100}},
+	} {
+		holdings, err := computeHoldings(c.trades)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		h := holdings["工行积存金"]
+		if !near(h.Grams, c.want.Grams) || !near(h.AvgCost, c.want.AvgCost) ||
+			!near(h.Realized, c.want.Realized) || !near(h.Fees, c.want.Fees) {
+			t.Errorf("%s: got %+v, want %+v", c.name, h, c.want)
+		}
+	}
+}
+
+func TestComputeHoldingsByInstrument(t *testing.T) {
+	other := buy(2, 2, 5, 300, 0)
+	other.Instrument = "浙商积存金"
+	holdings, err := computeHoldings([]*Trade{buy(1, 1, 10, 500, 0), other, sell(3, 3, 4, 510, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := holdings["工行积存金"]; h.Grams != 6 || h.AvgCost != 500 || h.Realized != 40 {
+		t.Errorf("工行积存金: %+v", h)
+	}
+	if h := holdings["浙商积存金"]; h.Grams != 5 || h.AvgCost != 300 || h.Realized != 0 {
+		t.Errorf("浙商积存金: %+v", h)
+	}
+}
+
+func TestComputeHoldingsErrors(t *testing.T) {
+	unknown := buy(1, 1, 10, 500, 0)
+	unknown.Side = "hold"
+	for name, trades := range map[string][]*Trade{
+		"超过持仓": {buy(1, 1, 10, 500, 0), sell(2, 2, 11, 510, 0)},
+		"没有持仓": {sell(1, 1, 1, 510, 0)},
+		"先卖后买": {buy(1, 2, 10, 500, 0), sell(2, 1, 10, 510, 0)},
+		"未知方向": {unknown},
+	} {
+		if _, err := computeHoldings(trades); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
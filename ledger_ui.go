@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+/* ---------- 持仓账本界面 ---------- */
+
+const tradeTimeLayout = "2006-01-02 15:04"
+
+var tradeSides = map[string]string{"buy": "买入", "sell": "卖出"}
+
+// holdingSummary 一个品种的持仓说明，bid 为 0 时不显示浮动盈亏
+func holdingSummary(name string, h Holding, bid float64) string {
+	text := fmt.Sprintf("%s：持仓 %.4f 克，均价 %.2f，已实现 %.2f，手续费 %.2f", name, h.Grams, h.AvgCost, h.Realized, h.Fees)
+	if bid > 0 && h.Grams > 0 {
+		fees := feesFor(name)
+		cost := h.Grams * h.AvgCost
+		text += fmt.Sprintf("，扣费后浮动 %.2f（回购价 %.2f，保本价 %.2f）", fees.profit(h.Grams, cost, bid), bid, fees.breakEven(h.Grams, cost))
+	}
+	return text
+}
+
+// showLedgerWindow 列出交易记录，修改后重新计算持仓并调用 onChange
+func showLedgerWindow(a fyne.App, monitor *Monitor, onChange func(), log func(string)) {
+	w := a.NewWindow("持仓账本")
+	w.Resize(fyne.NewSize(640, 420))
+
+	var trades []*Trade
+	selected := -1
+	list := widget.NewList(
+		func() int { return len(trades) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			t := trades[i]
+			o.(*widget.Label).SetText(fmt.Sprintf("%s | %s | %s | %.4f 克 | %.2f 元/克 | 手续费 %.2f",
+				time.UnixMilli(t.T).Format(tradeTimeLayout), t.Instrument, tradeSides[t.Side], t.Grams, t.Price, t.Fee))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) { selected = id }
+	list.OnUnselected = func(widget.ListItemID) { selected = -1 }
+
+	summary := widget.NewLabel("")
+	summary.Wrapping = fyne.TextWrapWord
+
+	refresh := func() {
+		var err error
+		trades, err = loadTrades()
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+		if err := monitor.ledger.reload(); err != nil {
+			log(fmt.Sprintf("加载持仓失败: %v", err))
+		}
+
+		var lines []string
+		for _, name := range cfg.Instruments {
+			h, ok := monitor.ledger.get(name)
+			if !ok {
+				continue
+			}
+			bid, _ := latestBid(name)
+			lines = append(lines, holdingSummary(name, h, bid))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "暂无交易记录")
+		}
+		summary.SetText(strings.Join(lines, "\n"))
+		onChange()
+	}
+
+	edit := func(t *Trade) {
+		timeEntry := widget.NewEntry()
+		timeEntry.SetText(time.UnixMilli(t.T).Format(tradeTimeLayout))
+		timeEntry.Validator = func(s string) error {
+			if _, err := time.ParseInLocation(tradeTimeLayout, strings.TrimSpace(s), time.Local); err != nil {
+				return fmt.Errorf("格式为 %s", tradeTimeLayout)
+			}
+			return nil
+		}
+
+		instrumentSelect := widget.NewSelect(cfg.Instruments, nil)
+		instrumentSelect.SetSelected(t.Instrument)
+
+		sideRadio := widget.NewRadioGroup([]string{"买入", "卖出"}, nil)
+		sideRadio.Horizontal = true
+		sideRadio.Required = true
+		sideRadio.SetSelected(tradeSides[t.Side])
+
+		gramsEntry := widget.NewEntry()
+		priceEntry := widget.NewEntry()
+		feeEntry := widget.NewEntry()
+		if t.ID != 0 {
+			gramsEntry.SetText(strconv.FormatFloat(t.Grams, 'f', -1, 64))
+			priceEntry.SetText(strconv.FormatFloat(t.Price, 'f', -1, 64))
+			feeEntry.SetText(strconv.FormatFloat(t.Fee, 'f', -1, 64))
+		}
+		gramsEntry.Validator = positive(true, false)
+		priceEntry.Validator = positive(true, false)
+		feeEntry.Validator = nonNegative(false)
+		feeEntry.SetPlaceHolder("留空按配置的费率计算")
+
+		items := []*widget.FormItem{
+			widget.NewFormItem("成交时间", timeEntry),
+			widget.NewFormItem("品种", instrumentSelect),
+			widget.NewFormItem("方向", sideRadio),
+			widget.NewFormItem("克数", gramsEntry),
+			widget.NewFormItem("单价（元/克）", priceEntry),
+			widget.NewFormItem("手续费（元）", feeEntry),
+		}
+		d := dialog.NewForm("编辑交易", "保存", "取消", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			ts, _ := time.ParseInLocation(tradeTimeLayout, strings.TrimSpace(timeEntry.Text), time.Local)
+			edited := *t
+			edited.T = ts.UnixMilli()
+			edited.Instrument = instrumentSelect.Selected
+			edited.Side = "buy"
+			if sideRadio.Selected == "卖出" {
+				edited.Side = "sell"
+			}
+			edited.Grams, _ = strconv.ParseFloat(gramsEntry.Text, 64)
+			edited.Price, _ = strconv.ParseFloat(priceEntry.Text, 64)
+			if fee := strings.TrimSpace(feeEntry.Text); fee != "" {
+				edited.Fee, _ = strconv.ParseFloat(fee, 64)
+			} else if edited.Side == "buy" {
+				edited.Fee = feesFor(edited.Instrument).Buy.Fee(edited.Grams * edited.Price)
+			} else {
+				edited.Fee = feesFor(edited.Instrument).Sell.Fee(edited.Grams * edited.Price)
+			}
+
+			// 修改后的记录需能算出有效持仓（卖出不能超过当时的持仓）
+			check := []*Trade{&edited}
+			for _, other := range trades {
+				if other.ID != edited.ID {
+					check = append(check, other)
+				}
+			}
+			if _, err := computeHoldings(check); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if err := saveTrade(&edited); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			log(fmt.Sprintf("已保存交易: %s %s %.4f 克 @ %.2f", edited.Instrument, tradeSides[edited.Side], edited.Grams, edited.Price))
+			refresh()
+		}, w)
+		d.Resize(fyne.NewSize(480, 0))
+		d.Show()
+	}
+
+	addButton := widget.NewButton("新增", func() {
+		edit(&Trade{Instrument: cfg.Instruments[0], T: time.Now().UnixMilli(), Side: "buy"})
+	})
+	editButton := widget.NewButton("编辑", func() {
+		if selected < 0 || selected >= len(trades) {
+			return
+		}
+		t := *trades[selected]
+		edit(&t)
+	})
+	deleteButton := widget.NewButton("删除", func() {
+		if selected < 0 || selected >= len(trades) {
+			return
+		}
+		t := trades[selected]
+		dialog.ShowConfirm("删除交易", "确定删除这笔交易？", func(ok bool) {
+			if !ok {
+				return
+			}
+			var rest []*Trade
+			for _, other := range trades {
+				if other.ID != t.ID {
+					rest = append(rest, other)
+				}
+			}
+			if _, err := computeHoldings(rest); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if err := deleteTrade(t.ID); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			log(fmt.Sprintf("已删除交易: %s %s %.4f 克", t.Instrument, tradeSides[t.Side], t.Grams))
+			refresh()
+		}, w)
+	})
+
+	refresh()
+	w.SetContent(container.NewBorder(
+		summary,
+		container.NewHBox(addButton, editButton, deleteButton),
+		nil,
+		nil,
+		list,
+	))
+	w.Show()
+}
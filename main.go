@@ -2,13 +2,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
-	"regexp"
+	"math"
+	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,24 +21,16 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-	"golang.org/x/sys/windows"
 
 	"gopkg.in/ini.v1"      // INI 解析
 	_ "modernc.org/sqlite" // SQLite 驱动（纯 Go）
 )
 
-type Quote struct {
-	Data []struct {
-		QuoteData struct {
-			Q63 string `json:"q63"`
-			Q67 string `json:"q67"`
-		} `json:"quote"`
-	} `json:"data"`
-}
-
 type PriceInfo struct {
 	T     int64
-	Price float64
+	Price float64 // 最新价，用于统计
+	Bid   float64 // 回购价
+	Ask   float64 // 买入价
 }
 
 /* ---------- 配置 ---------- */
@@ -47,6 +39,18 @@ type Config struct {
 	Notify      bool
 	Key         string
 	SqlitePath  string
+	Instruments []string // 监控的品种，对应报价列表中的名称
+	Interval    int      // 间隔时间（秒），后台模式使用
+	LogFile     string   // 后台模式日志文件，为空输出到标准输出
+	Popup       string   // 本地弹窗方式：auto/system/fyne/none
+	KeepRunning bool     // 提醒后继续监控
+	Rearm       RearmPolicy
+	Channels    NotifyConfig
+	Retention   RetentionConfig
+	Strategy    Strategy
+	HTTP        HTTPConfig
+	Targets     map[string]Target
+	Fees        map[string]Fees
 }
 
 var cfg Config
@@ -58,6 +62,15 @@ func loadConfig() error {
 		Notify:      false,
 		Key:         "SCT291613TsbPfeE1oOFP9BT5cQIhHoYZA",
 		SqlitePath:  "./gold_price.db",
+		Instruments: []string{"工行积存金"},
+		Interval:    10,
+		Popup:       "auto",
+		Rearm:       RearmPolicy{Yuan: 2, Minutes: 30},
+		Channels:    NotifyConfig{ServerChan: true},
+		Retention:   RetentionConfig{RawDays: 365, MinuteDays: 730, HourDays: 3650, Interval: 60},
+		Strategy:    Strategy{Instrument: "工行积存金", Kind: "grid", Amount: 1000, Step: 5, Rise: 8, Days: 7, MaxLots: 10},
+		Targets:     map[string]Target{},
+		Fees:        map[string]Fees{},
 	}
 
 	// 读取 conf.ini
@@ -81,6 +94,118 @@ func loadConfig() error {
 	if v := sec.Key("sqlite_path").String(); v != "" {
 		cfg.SqlitePath = v
 	}
+	if v := sec.Key("instruments").String(); v != "" {
+		var names []string
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			cfg.Instruments = names
+		}
+	}
+	if v := sec.Key("interval").String(); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			cfg.Interval = i
+		}
+	}
+	if v := sec.Key("log_file").String(); v != "" {
+		cfg.LogFile = v
+	}
+	if v := sec.Key("popup").String(); v != "" {
+		cfg.Popup = strings.ToLower(v)
+	}
+	if v := sec.Key("alert_mode").String(); v != "" {
+		cfg.KeepRunning = strings.ToLower(v) == "continue"
+	}
+	cfg.Rearm.Yuan = sec.Key("rearm_yuan").MustFloat64(cfg.Rearm.Yuan)
+	cfg.Rearm.Minutes = sec.Key("rearm_minutes").MustInt(cfg.Rearm.Minutes)
+
+	// 通知渠道，每个渠道一个小节
+	cfg.Channels.ServerChan = iniFile.Section("serverchan").Key("enable").MustBool(true)
+	channels := map[string]any{
+		"webhook":  &cfg.Channels.Webhook,
+		"smtp":     &cfg.Channels.SMTP,
+		"telegram": &cfg.Channels.Telegram,
+		"dingtalk": &cfg.Channels.DingTalk,
+		"feishu":   &cfg.Channels.Feishu,
+		"wecom":    &cfg.Channels.WeCom,
+	}
+	for name, conf := range channels {
+		if err := iniFile.Section(name).MapTo(conf); err != nil {
+			return fmt.Errorf("通知渠道 %s 配置错误: %w", name, err)
+		}
+	}
+
+	// 数据保留天数，超期前先汇总为K线
+	if err := iniFile.Section("retention").MapTo(&cfg.Retention); err != nil {
+		return fmt.Errorf("数据保留配置错误: %w", err)
+	}
+
+	// 内置 HTTP 接口
+	if err := iniFile.Section("http").MapTo(&cfg.HTTP); err != nil {
+		return fmt.Errorf("HTTP 配置错误: %w", err)
+	}
+
+	// 网格/定投策略，模拟和实时建议共用
+	cfg.Strategy.Instrument = cfg.Instruments[0]
+	if err := iniFile.Section("strategy").MapTo(&cfg.Strategy); err != nil {
+		return fmt.Errorf("策略配置错误: %w", err)
+	}
+	// 留空时取第一个品种（ini 遇到空值时保留原值，这里不依赖该行为）；
+	// 模拟和实时建议都以它为默认品种，需为监控中的品种
+	if cfg.Strategy.Instrument == "" {
+		cfg.Strategy.Instrument = cfg.Instruments[0]
+	}
+	if !slices.Contains(cfg.Instruments, cfg.Strategy.Instrument) {
+		return fmt.Errorf("策略配置错误: 品种 %s 不在监控品种中", cfg.Strategy.Instrument)
+	}
+
+	// 手续费，[fee] 为默认值，品种小节中的同名键覆盖
+	fee := iniFile.Section("fee")
+	readFees := func(s *ini.Section, def Fees) Fees {
+		return Fees{
+			Buy: FeeModel{
+				Pct:   s.Key("buy_pct").MustFloat64(def.Buy.Pct),
+				Fixed: s.Key("buy_fixed").MustFloat64(def.Buy.Fixed),
+				Min:   s.Key("buy_min").MustFloat64(def.Buy.Min),
+			},
+			Sell: FeeModel{
+				Pct:   s.Key("sell_pct").MustFloat64(def.Sell.Pct),
+				Fixed: s.Key("sell_fixed").MustFloat64(def.Sell.Fixed),
+				Min:   s.Key("sell_min").MustFloat64(def.Sell.Min),
+			},
+			Notional: s.Key("notional").MustFloat64(def.Notional),
+		}
+	}
+	fees := readFees(fee, defaultFees)
+	if err := fees.check(); err != nil {
+		return fmt.Errorf("手续费配置错误: [fee] %w", err)
+	}
+
+	// 每个品种一个小节，统计时间未配置时取全局 stats
+	stats := sec.Key("stats").MustInt(0)
+	for _, name := range cfg.Instruments {
+		isec := iniFile.Section(name)
+		cfg.Fees[name] = readFees(isec, fees)
+		if err := cfg.Fees[name].check(); err != nil {
+			return fmt.Errorf("手续费配置错误: [%s] %w", name, err)
+		}
+		cfg.Targets[name] = Target{
+			BuyPrice:        isec.Key("buy_price").MustFloat64(0),
+			TargetBuyPrice:  isec.Key("target_buy").MustFloat64(0),
+			TargetSellPrice: isec.Key("target_sell").MustFloat64(0),
+			StatsMinutes:    isec.Key("stats").MustInt(stats),
+			DropPct:         isec.Key("drop_pct").MustFloat64(0),
+			RisePct:         isec.Key("rise_pct").MustFloat64(0),
+			StdMult:         isec.Key("std_mult").MustFloat64(0),
+			VolWindow:       isec.Key("vol_window").MustInt(60),
+			ProfitYuan:      isec.Key("profit_yuan").MustFloat64(0),
+			ProfitPct:       isec.Key("profit_pct").MustFloat64(0),
+			BreakEvenAlert:  isec.Key("break_even_alert").MustBool(false),
+		}
+	}
 	return nil
 }
 
@@ -88,7 +213,6 @@ func loadConfig() error {
 var dbMutex sync.Mutex
 var db *sql.DB
 var insertStmt *sql.Stmt
-var recLis []*PriceInfo
 
 func initDB() error {
 	var err error
@@ -101,30 +225,18 @@ func initDB() error {
 		return err
 	}
 
-	// 创建表
-	_, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS price_log (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            ts TEXT NOT NULL,
-            price REAL NOT NULL
-        );
-    `)
-	if err != nil {
-		return err
-	}
-
-	cutoff := time.Now().AddDate(-1, 0, 0).Format(time.RFC3339) // 一年前
-
-	_, err = db.Exec(`DELETE FROM price_log WHERE ts < ?`, cutoff)
-	if err != nil {
+	// 按版本升级表结构
+	if err = migrateDB(); err != nil {
 		return err
 	}
 
-	//rowsAffected, _ := result.RowsAffected()
-	//fmt.Printf("已删除 %d 条一年前的记录\n", rowsAffected)
+	// 过期数据由 startMaintenance 汇总后定期清理
 
 	// 准备插入语句
-	insertStmt, err = db.Prepare("INSERT INTO price_log(ts, price) VALUES(?, ?)")
+	insertStmt, err = db.Prepare(`
+        INSERT INTO price_log(ts, instrument, price, bid, ask, open, high, low, chg, quote_ts)
+        VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `)
 	if err != nil {
 		return err
 	}
@@ -134,24 +246,23 @@ func initDB() error {
 	return nil
 }
 
-// 查询最近12小时的数据
-func getRecentPriceData() []*PriceInfo {
+// 查询某品种 since 之后的数据
+func getRecentPriceData(instrument string, since time.Time) []*PriceInfo {
 	dbMutex.Lock()
 	defer dbMutex.Unlock()
 	if insertStmt == nil {
 		return []*PriceInfo{}
 	}
-	// 计算12小时前的时间点（RFC3339格式）
-	longTimeAgo := time.Now().Add(-12 * time.Hour).Format(time.RFC3339)
+	longTimeAgo := since.UnixMilli()
 
 	query := `
-        SELECT ts, price 
+        SELECT ts, price, COALESCE(bid, price), COALESCE(ask, price)
         FROM price_log 
-        WHERE ts >= ? 
+        WHERE ts >= ? AND instrument = ?
         ORDER BY ts ASC
     `
 
-	rows, err := db.Query(query, longTimeAgo)
+	rows, err := db.Query(query, longTimeAgo, instrument)
 	if err != nil {
 		return []*PriceInfo{}
 	}
@@ -160,24 +271,20 @@ func getRecentPriceData() []*PriceInfo {
 	var priceData []*PriceInfo
 
 	for rows.Next() {
-		var tsStr string
-		var price float64
+		var ts int64
+		var price, bid, ask float64
 		var priceInfo PriceInfo
 
-		err := rows.Scan(&tsStr, &price)
+		err := rows.Scan(&ts, &price, &bid, &ask)
 		if err != nil {
 			return []*PriceInfo{}
 		}
 
-		// 将RFC3339时间字符串转换为time.Time
-		t, err := time.Parse(time.RFC3339, tsStr)
-		if err != nil {
-			return []*PriceInfo{}
-		}
-
-		// 转换为Unix时间戳（秒）
-		priceInfo.T = t.Unix()
+		// 毫秒转换为Unix时间戳（秒）
+		priceInfo.T = ts / 1000
 		priceInfo.Price = price
+		priceInfo.Bid = bid
+		priceInfo.Ask = ask
 
 		priceData = append(priceData, &priceInfo)
 	}
@@ -190,7 +297,7 @@ func getRecentPriceData() []*PriceInfo {
 }
 
 // 记录价格到 SQLite（仅 interval == 15）
-func logPriceToDB(price float64) {
+func logPriceToDB(instrument string, quote *PriceQuote) {
 	dbMutex.Lock()
 	defer dbMutex.Unlock()
 
@@ -198,10 +305,12 @@ func logPriceToDB(price float64) {
 		return
 	}
 
-	ts := time.Now().Format(time.RFC3339)
-	_, err := insertStmt.Exec(ts, price)
+	ts := time.Now().UnixMilli()
+	_, err := insertStmt.Exec(ts, instrument, quote.Last, quote.Bid, quote.Ask,
+		quote.Open, quote.High, quote.Low, quote.Change, quote.T.UnixMilli())
 	if err != nil {
 		// 记录错误但不中断主流程
+		dbWriteFailures.inc()
 		fmt.Printf("SQLite 写入失败: %v\n", err)
 	}
 }
@@ -210,9 +319,13 @@ func logPriceToDB(price float64) {
 var maxLogLines int
 var notify bool
 var key string
+var headless bool
+var flagTarget Target // 命令行指定的提醒参数，覆盖所有品种
 
 func init() {
-	_ = loadConfig() // 加载配置
+	if err := loadConfig(); err != nil { // 加载配置
+		fmt.Fprintln(os.Stderr, err)
+	}
 	maxLogLines = cfg.MaxLogLines
 	notify = cfg.Notify
 	key = cfg.Key
@@ -221,66 +334,37 @@ func init() {
 	flag.IntVar(&maxLogLines, "n", maxLogLines, "显示多少行日志")
 	flag.BoolVar(&notify, "notify", notify, "是否通知")
 	flag.StringVar(&key, "k", key, "显示通知所用key，参考")
-	flag.Parse()
+	flag.BoolVar(&headless, "headless", false, "无界面后台运行")
+	flag.IntVar(&cfg.Interval, "interval", cfg.Interval, "间隔时间（秒）")
+	flag.StringVar(&cfg.LogFile, "log", cfg.LogFile, "后台模式日志文件")
+	flag.BoolVar(&cfg.KeepRunning, "keep", cfg.KeepRunning, "提醒后继续监控")
+	flag.Float64Var(&flagTarget.BuyPrice, "buy", 0, "买入平均价格（所有品种）")
+	flag.Float64Var(&flagTarget.TargetBuyPrice, "target-buy", 0, "目标买入价格（所有品种）")
+	flag.Float64Var(&flagTarget.TargetSellPrice, "target-sell", 0, "目标卖出价格（所有品种）")
+	flag.IntVar(&flagTarget.StatsMinutes, "stats", 0, "统计时间（分，所有品种）")
 }
 
-func showAlertPopup(message string) {
-	// 使用简单可靠的方法创建置顶弹窗
-	msg, _ := windows.UTF16PtrFromString("黄金价格提醒")
-	content, _ := windows.UTF16PtrFromString(message)
-	// 使用MB_TOPMOST确保置顶，MB_SETFOREGROUND确保获得焦点
-	flags := uint32(windows.MB_ICONINFORMATION | windows.MB_OK | windows.MB_SETFOREGROUND | windows.MB_TOPMOST)
-	// 单次显示，确保置顶
-	windows.MessageBox(0, content, msg, flags)
-}
-
-//func showAlertPopup(message string) {
-//	// 使用简单可靠的方法创建置顶弹窗
-//	msg, _ := windows.UTF16PtrFromString("黄金价格提醒")
-//	content, _ := windows.UTF16PtrFromString(message)
-//	// 使用MB_TOPMOST确保置顶，MB_SETFOREGROUND确保获得焦点
-//	flags := uint32(windows.MB_ICONINFORMATION | windows.MB_OK | windows.MB_SETFOREGROUND)
-//	// 第一次尝试：使用默认窗口
-//	windows.MessageBox(0, content, msg, flags)
-//	// 短暂延迟后再次尝试，确保置顶
-//	time.Sleep(100 * time.Millisecond)
-//	// 第二次尝试：添加MB_TOPMOST
-//	windows.MessageBox(0, content, msg, flags|windows.MB_TOPMOST)
-//}
-
-func scSend(sendkey, title, desp string) (map[string]interface{}, error) {
-	var url string
-	if strings.HasPrefix(sendkey, "sctp") {
-		parts := strings.SplitN(sendkey, "t", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("无效的 sendkey 格式: %s", sendkey)
+// parseFlags 在 main 中解析命令行，init 中解析会与 go test 的参数冲突
+func parseFlags() {
+	flag.Parse()
+	t := flagTarget
+
+	// 命令行指定的参数覆盖配置文件
+	flag.Visit(func(f *flag.Flag) {
+		for name, target := range cfg.Targets {
+			switch f.Name {
+			case "buy":
+				target.BuyPrice = t.BuyPrice
+			case "target-buy":
+				target.TargetBuyPrice = t.TargetBuyPrice
+			case "target-sell":
+				target.TargetSellPrice = t.TargetSellPrice
+			case "stats":
+				target.StatsMinutes = t.StatsMinutes
+			}
+			cfg.Targets[name] = target
 		}
-		num := strings.TrimPrefix(parts[0], "sctp")
-		url = fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", num, sendkey)
-	} else {
-		url = fmt.Sprintf("https://sctapi.ftqq.com/%s.send", sendkey)
-	}
-
-	payload := map[string]string{
-		"title": title,
-		"desp":  desp,
-	}
-	jsonData, _ := json.Marshal(payload)
-
-	req, _ := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
-	req.Header.Set("Content-Type", "application/json;charset=utf-8")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	var result map[string]interface{}
-	_ = json.Unmarshal(body, &result)
-	return result, nil
+	})
 }
 
 func getMedian(nums []float64) float64 {
@@ -305,14 +389,13 @@ func getMedian(nums []float64) float64 {
 	return (mid1 + mid2) / 2
 }
 
-func getStatsPrice(recLis []*PriceInfo, n int64) (maxVal, minVal, avgVal, medVal float64) {
+func getStatsPrice(recLis []*PriceInfo, n, nowTime int64) (maxVal, minVal, avgVal, medVal float64) {
 	// 初始化最大值、最小值和总和
 	maxVal = 0
 	minVal = 0.0
 	sum := 0.0
 
-	// 遍历切片计算
-	nowTime := time.Now().Unix()
+	// 遍历切片计算，nowTime 为统计截止时间（秒）
 	useN := 0
 	//fmt.Printf("计算%d分钟内\n", n)
 	//fmt.Println("长度", len(recLis))
@@ -341,15 +424,252 @@ func getStatsPrice(recLis []*PriceInfo, n int64) (maxVal, minVal, avgVal, medVal
 	return
 }
 
+// getStdPrice 统计窗口内的标准差，以及窗口内第一个价格
+func getStdPrice(recLis []*PriceInfo, n, nowTime int64) (stdVal, firstVal float64) {
+	priceList := make([]float64, 0, 120)
+	sum := 0.0
+	for _, itm := range recLis {
+		if itm.T+n*60 < nowTime {
+			continue
+		}
+		if len(priceList) == 0 {
+			firstVal = itm.Price
+		}
+		priceList = append(priceList, itm.Price)
+		sum += itm.Price
+	}
+	if len(priceList) < 2 {
+		return 0, firstVal
+	}
+
+	avg := sum / float64(len(priceList))
+	variance := 0.0
+	for _, p := range priceList {
+		variance += (p - avg) * (p - avg)
+	}
+	stdVal = math.Sqrt(variance / float64(len(priceList)-1))
+	return
+}
+
+// instrumentView 单个品种的输入框
+type instrumentView struct {
+	name                 string
+	buyPriceEntry        *widget.Entry
+	targetBuyPriceEntry  *widget.Entry
+	targetSellPriceEntry *widget.Entry
+	statsEntry           *widget.Entry
+	currEntry            *widget.Entry
+	profitEntry          *widget.Entry
+	profitLabel          *widget.Label
+	holdingEntry         *widget.Entry
+	profitYuanEntry      *widget.Entry
+	profitPctEntry       *widget.Entry
+	breakEvenCheck       *widget.Check
+	profitTargetEntry    *widget.Entry
+	dropPctEntry         *widget.Entry
+	risePctEntry         *widget.Entry
+	stdMultEntry         *widget.Entry
+	volWindowEntry       *widget.Entry
+}
+
+func newInstrumentView(name string) *instrumentView {
+	v := &instrumentView{
+		name:                 name,
+		buyPriceEntry:        widget.NewEntry(),
+		targetBuyPriceEntry:  widget.NewEntry(),
+		targetSellPriceEntry: widget.NewEntry(),
+		statsEntry:           widget.NewEntry(),
+		currEntry:            widget.NewEntry(),
+		profitEntry:          widget.NewEntry(),
+		profitLabel:          widget.NewLabel("当前万元收益："),
+		holdingEntry:         widget.NewEntry(),
+		profitYuanEntry:      widget.NewEntry(),
+		profitPctEntry:       widget.NewEntry(),
+		breakEvenCheck:       widget.NewCheck("回到保本价以上时提醒", nil),
+		profitTargetEntry:    widget.NewEntry(),
+		dropPctEntry:         widget.NewEntry(),
+		risePctEntry:         widget.NewEntry(),
+		stdMultEntry:         widget.NewEntry(),
+		volWindowEntry:       widget.NewEntry(),
+	}
+	v.buyPriceEntry.SetPlaceHolder("请输入买入价格（如 935.5）")
+	v.targetBuyPriceEntry.SetPlaceHolder("请输入目标买入价格（如 900.0）")
+	v.targetSellPriceEntry.SetPlaceHolder("请输入目标卖出价格（如 970.0）")
+	v.statsEntry.SetPlaceHolder("请输入统计时间（分钟，如 10）")
+	v.dropPctEntry.SetPlaceHolder("窗口内从最高点下跌百分比（如 1.5，留空不提醒）")
+	v.risePctEntry.SetPlaceHolder("窗口内从最低点上涨百分比（如 1.5，留空不提醒）")
+	v.stdMultEntry.SetPlaceHolder("偏离均值超过几倍标准差（如 2，留空不提醒）")
+	v.volWindowEntry.SetPlaceHolder("波动统计窗口（分钟，如 60）")
+	v.holdingEntry.SetPlaceHolder("无交易记录，可在持仓账本中添加")
+	v.profitYuanEntry.SetPlaceHolder("扣费后净收益达到多少元（如 500，留空不提醒）")
+	v.profitPctEntry.SetPlaceHolder("扣费后收益率达到百分之几（如 3，留空不提醒）")
+	v.profitTargetEntry.SetPlaceHolder("由持仓成本和手续费推算")
+
+	// 配置文件中的参数作为初始值
+	t := cfg.Targets[name]
+	if t.BuyPrice > 0 {
+		v.buyPriceEntry.SetText(strconv.FormatFloat(t.BuyPrice, 'f', -1, 64))
+	}
+	if t.TargetBuyPrice > 0 {
+		v.targetBuyPriceEntry.SetText(strconv.FormatFloat(t.TargetBuyPrice, 'f', -1, 64))
+	}
+	if t.TargetSellPrice > 0 {
+		v.targetSellPriceEntry.SetText(strconv.FormatFloat(t.TargetSellPrice, 'f', -1, 64))
+	}
+	if t.StatsMinutes > 0 {
+		v.statsEntry.SetText(strconv.Itoa(t.StatsMinutes))
+	}
+	if t.DropPct > 0 {
+		v.dropPctEntry.SetText(strconv.FormatFloat(t.DropPct, 'f', -1, 64))
+	}
+	if t.RisePct > 0 {
+		v.risePctEntry.SetText(strconv.FormatFloat(t.RisePct, 'f', -1, 64))
+	}
+	if t.StdMult > 0 {
+		v.stdMultEntry.SetText(strconv.FormatFloat(t.StdMult, 'f', -1, 64))
+	}
+	if t.VolWindow > 0 {
+		v.volWindowEntry.SetText(strconv.Itoa(t.VolWindow))
+	}
+	if t.ProfitYuan > 0 {
+		v.profitYuanEntry.SetText(strconv.FormatFloat(t.ProfitYuan, 'f', -1, 64))
+	}
+	if t.ProfitPct > 0 {
+		v.profitPctEntry.SetText(strconv.FormatFloat(t.ProfitPct, 'f', -1, 64))
+	}
+	v.breakEvenCheck.SetChecked(t.BreakEvenAlert)
+	return v
+}
+
+// 每个品种一张卡片，并排显示
+func (v *instrumentView) card() fyne.CanvasObject {
+	form := container.New(layout.NewFormLayout(),
+		widget.NewLabel("买入平均价格："), v.buyPriceEntry,
+		widget.NewLabel("目标买入价格："), v.targetBuyPriceEntry,
+		widget.NewLabel("目标卖出价格："), v.targetSellPriceEntry,
+		widget.NewLabel("当前买卖价格："), v.currEntry,
+		v.profitLabel, v.profitEntry,
+		widget.NewLabel("持仓情况："), v.holdingEntry,
+		widget.NewLabel("统计时间（分）："), v.statsEntry,
+	)
+	volForm := container.New(layout.NewFormLayout(),
+		widget.NewLabel("跌幅提醒（%）："), v.dropPctEntry,
+		widget.NewLabel("涨幅提醒（%）："), v.risePctEntry,
+		widget.NewLabel("波动倍数（σ）："), v.stdMultEntry,
+		widget.NewLabel("波动窗口（分）："), v.volWindowEntry,
+	)
+	profitForm := container.New(layout.NewFormLayout(),
+		widget.NewLabel("目标收益（元）："), v.profitYuanEntry,
+		widget.NewLabel("目标收益率（%）："), v.profitPctEntry,
+		widget.NewLabel("对应回购价："), v.profitTargetEntry,
+		widget.NewLabel("回本提醒："), v.breakEvenCheck,
+	)
+	extra := widget.NewAccordion(
+		widget.NewAccordionItem("涨跌幅与波动提醒", volForm),
+		widget.NewAccordionItem("收益目标提醒", profitForm),
+	)
+	extra.MultiOpen = true
+	return widget.NewCard(v.name, "", container.NewVBox(form, extra))
+}
+
+// applyHolding 有持仓时买入平均价格取账本的加权平均成本且不可编辑，收益显示为浮动盈亏
+func (v *instrumentView) applyHolding(h Holding, ok bool) {
+	if !ok {
+		v.holdingEntry.SetText("")
+		v.buyPriceEntry.Enable()
+		v.profitLabel.SetText("当前万元收益：")
+		return
+	}
+	v.holdingEntry.SetText(fmt.Sprintf("%.4f 克 | 已实现 %.2f", h.Grams, h.Realized))
+	if h.Grams > 0 {
+		v.buyPriceEntry.SetText(strconv.FormatFloat(math.Round(h.AvgCost*100)/100, 'f', -1, 64))
+		v.buyPriceEntry.Disable()
+		v.profitLabel.SetText("持仓浮动盈亏：")
+	} else {
+		v.buyPriceEntry.Enable()
+		v.profitLabel.SetText("当前万元收益：")
+	}
+}
+
+// 从输入框读取本轮参数
+func (v *instrumentView) target() Target {
+	buyPrice, _ := strconv.ParseFloat(v.buyPriceEntry.Text, 64)
+	targetBuyPrice, _ := strconv.ParseFloat(v.targetBuyPriceEntry.Text, 64)
+	targetSellPrice, _ := strconv.ParseFloat(v.targetSellPriceEntry.Text, 64)
+	statsNum, _ := strconv.Atoi(v.statsEntry.Text)
+	dropPct, _ := strconv.ParseFloat(v.dropPctEntry.Text, 64)
+	risePct, _ := strconv.ParseFloat(v.risePctEntry.Text, 64)
+	stdMult, _ := strconv.ParseFloat(v.stdMultEntry.Text, 64)
+	volWindow, _ := strconv.Atoi(v.volWindowEntry.Text)
+	profitYuan, _ := strconv.ParseFloat(v.profitYuanEntry.Text, 64)
+	profitPct, _ := strconv.ParseFloat(v.profitPctEntry.Text, 64)
+	return Target{
+		BuyPrice:        buyPrice,
+		TargetBuyPrice:  targetBuyPrice,
+		TargetSellPrice: targetSellPrice,
+		StatsMinutes:    statsNum,
+		DropPct:         dropPct,
+		RisePct:         risePct,
+		StdMult:         stdMult,
+		VolWindow:       volWindow,
+		ProfitYuan:      profitYuan,
+		ProfitPct:       profitPct,
+		BreakEvenAlert:  v.breakEvenCheck.Checked,
+	}
+}
+
+// updateProfitTarget 按当前持仓、买入均价和收益目标重新推算对应回购价
+func (v *instrumentView) updateProfitTarget(h Holding) {
+	fees := feesFor(v.name)
+	t := v.target()
+	grams, cost := fees.position(t.BuyPrice, h)
+	if price := t.profitTarget(grams, cost, fees); price > 0 {
+		v.profitTargetEntry.SetText(fmt.Sprintf("%.2f（保本价 %.2f）", price, fees.breakEven(grams, cost)))
+	} else {
+		v.profitTargetEntry.SetText("")
+	}
+}
+
 func main() {
+	parseFlags()
+
 	// 初始化 SQLite
 	if err := initDB(); err != nil {
 		panic("SQLite 初始化失败: " + err.Error())
 	}
-	recLis = getRecentPriceData()
-	//fmt.Printf("获取到%d条记录\n", len(recLis))
-	//fmt.Println((*recLis[0]).T, (*recLis[0]).Price)
-	//fmt.Println((*recLis[len(recLis)-1]).T, (*recLis[len(recLis)-1]).Price)
+
+	// 价格来源，同一分类下的品种共用一次请求
+	feed := newJijinhaoFeed("225")
+	var sources []PriceSource
+	for _, name := range cfg.Instruments {
+		sources = append(sources, feed.source(name))
+	}
+
+	// 子命令
+	commands := map[string]func([]string) error{
+		"backtest": runBacktestCommand,
+		"strategy": runStrategyCommand,
+	}
+	if run, ok := commands[flag.Arg(0)]; ok {
+		if err := run(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if headless {
+		if err := runHeadless(sources); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var views []*instrumentView
+	for _, name := range cfg.Instruments {
+		views = append(views, newInstrumentView(name))
+	}
 
 	// Fyne UI
 	myApp := app.New()
@@ -357,26 +677,30 @@ func main() {
 	myWindow := myApp.NewWindow("黄金价格监控")
 	myWindow.SetIcon(resourceIconPng)
 	//myWindow.Resize(fyne.NewSize(720, 540))
-	myWindow.Resize(fyne.NewSize(720, 0))
+	myWindow.Resize(fyne.NewSize(float32(max(720, 360*len(views))), 0))
 
 	// 输入框
-	buyPriceEntry := widget.NewEntry()
-	buyPriceEntry.SetPlaceHolder("请输入买入价格（如 935.5）")
-	targetBuyPriceEntry := widget.NewEntry()
-	targetBuyPriceEntry.SetPlaceHolder("请输入目标买入价格（如 900.0）")
-	targetSellPriceEntry := widget.NewEntry()
-	targetSellPriceEntry.SetPlaceHolder("请输入目标卖出价格（如 970.0）")
 	intervalEntry := widget.NewEntry()
 	intervalEntry.SetPlaceHolder("请输入间隔时间（秒，如 10）")
-	statsEntry := widget.NewEntry()
-	statsEntry.SetPlaceHolder("请输入统计时间（分钟，如 10）")
-	profitEntry := widget.NewEntry()
-	currEntry := widget.NewEntry()
+	if cfg.Interval > 0 {
+		intervalEntry.SetText(strconv.Itoa(cfg.Interval))
+	}
+	rearmYuanEntry := widget.NewEntry()
+	rearmYuanEntry.SetPlaceHolder("价格回到目标价之外多少元后再次提醒（如 2）")
+	rearmYuanEntry.SetText(strconv.FormatFloat(cfg.Rearm.Yuan, 'f', -1, 64))
+	rearmMinutesEntry := widget.NewEntry()
+	rearmMinutesEntry.SetPlaceHolder("距上次提醒多少分钟后再次提醒（0 不限）")
+	rearmMinutesEntry.SetText(strconv.Itoa(cfg.Rearm.Minutes))
+
+	// 提醒模式
+	keepRunningCheck := widget.NewCheck("提醒后继续监控", nil)
+	keepRunningCheck.SetChecked(cfg.KeepRunning)
 
 	// 通知开关
 	notifyCheck := widget.NewCheck("启用通知提醒", func(checked bool) {
 		notify = checked
 	})
+	notifyCheck.SetChecked(notify)
 
 	// 日志区
 	logText := widget.NewLabel("")
@@ -386,11 +710,9 @@ func main() {
 
 	// 运行按钮
 	runButton := widget.NewButton("运行", nil)
-	isRunning := false
 	var logMutex sync.Mutex
 	var buttonMutex sync.Mutex
-	var stopChan chan struct{}
-	var errList []int
+	var cancelRun context.CancelFunc
 	logLines := make([]string, 0, maxLogLines+2)
 
 	// 日志函数
@@ -412,182 +734,238 @@ func main() {
 		logMutex.Unlock()
 	}
 
-	// 获取价格
-	fetchPrice := func() (float64, error) {
-		client := &http.Client{}
-		req, _ := http.NewRequest("GET", "https://api.jijinhao.com/realtime/quotejs.htm", nil)
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-		req.Header.Set("Referer", "https://www.cngold.org/paper/gonghang.html")
-		q := req.URL.Query()
-		q.Add("categoryId", "225")
-		q.Add("currentPage", "1")
-		q.Add("pageSize", "8")
-		q.Add("_", strconv.FormatInt(time.Now().UnixMilli(), 10))
-		req.URL.RawQuery = q.Encode()
-
-		resp, err := client.Do(req)
-		if err != nil {
-			return 0, err
+	// 监控循环，参数每轮从输入框读取
+	// 输入无效时沿用上一轮有效的参数，改好后再生效
+	validator := &formValidator{}
+	var lastSettings *MonitorSettings
+	monitor := newMonitor(sources, func() (*MonitorSettings, error) {
+		if !validator.valid() {
+			if lastSettings != nil {
+				return lastSettings, nil
+			}
+			return nil, fmt.Errorf("参数无效")
 		}
-		defer resp.Body.Close()
-		body, _ := io.ReadAll(resp.Body)
-
-		re := regexp.MustCompile(`quot_str = \[(.+)\]`)
-		matches := re.FindStringSubmatch(string(body))
-		if len(matches) < 2 {
-			return 0, fmt.Errorf("无法解析 quot_str")
+		interval, _ := strconv.Atoi(intervalEntry.Text)
+		rearmYuan, _ := strconv.ParseFloat(rearmYuanEntry.Text, 64)
+		rearmMinutes, _ := strconv.Atoi(rearmMinutesEntry.Text)
+		st := &MonitorSettings{
+			Interval:    interval,
+			KeepRunning: keepRunningCheck.Checked,
+			Rearm:       RearmPolicy{Yuan: rearmYuan, Minutes: rearmMinutes},
+			Targets:     map[string]Target{},
 		}
-
-		var quotes Quote
-		if err := json.Unmarshal([]byte(matches[1]), &quotes); err != nil {
-			return 0, err
+		for _, v := range views {
+			st.Targets[v.name] = v.target()
 		}
-
-		for _, item := range quotes.Data {
-			if item.QuoteData.Q67 == "工行积存金" {
-				price, _ := strconv.ParseFloat(item.QuoteData.Q63, 64)
-				return price, nil
+		lastSettings = st
+		return st, nil
+	}, log)
+	monitor.popup = newPopup(cfg.Popup, myApp, myWindow)
+
+	// 走势图，参考线取输入框中的买入均价、目标价和收益目标对应的价格
+	chart := newChartPanel(func(name string) []chartLine {
+		var lines []chartLine
+		for _, v := range views {
+			if v.name != name {
+				continue
+			}
+			t := v.target()
+			fees := feesFor(name)
+			h, _ := monitor.ledger.get(name)
+			grams, cost := fees.position(t.BuyPrice, h)
+			for _, l := range []chartLine{
+				{"均价", t.BuyPrice, colorBuy},
+				{"买入", t.TargetBuyPrice, colorTargetBuy},
+				{"卖出", t.TargetSellPrice, colorTargetSel},
+				{"收益", t.profitTarget(grams, cost, fees), colorProfit},
+			} {
+				if l.value > 0 {
+					lines = append(lines, l)
+				}
+			}
+		}
+		return lines
+	}, log)
+	for _, v := range views {
+		for _, e := range []*widget.Entry{v.buyPriceEntry, v.targetBuyPriceEntry, v.targetSellPriceEntry, v.profitYuanEntry, v.profitPctEntry} {
+			e.OnChanged = func(string) {
+				h, _ := monitor.ledger.get(v.name)
+				v.updateProfitTarget(h)
+				chart.refreshLines()
 			}
 		}
-		return 0, fmt.Errorf("未找到工行积存金")
 	}
 
-	// 主循环
-	go func() {
-		for {
-			select {
-			case <-stopChan:
-				return
-			default:
-				if !isRunning {
-					time.Sleep(100 * time.Millisecond)
-					continue
-				}
-
-				// 解析输入
-				buyPrice, _ := strconv.ParseFloat(buyPriceEntry.Text, 64)
-				targetBuyPrice, _ := strconv.ParseFloat(targetBuyPriceEntry.Text, 64)
-				targetSellPrice, _ := strconv.ParseFloat(targetSellPriceEntry.Text, 64)
-				interval, err := strconv.Atoi(intervalEntry.Text)
-				if err != nil || interval <= 0 {
-					log("间隔时间无效")
-					time.Sleep(1 * time.Second)
-					continue
-				}
-
-				statsNum, err := strconv.Atoi(statsEntry.Text)
-				if err != nil || statsNum <= 0 {
-					log("统计时间无效")
-				}
-
-				price, err := fetchPrice()
-				if err != nil {
-					log(fmt.Sprintf("错误: %v", err))
-					errList = append(errList, 1)
-					if len(errList) > 5 {
-						errList = errList[len(errList)-5:]
-					}
-					if len(errList) == 5 && sum(errList) == 5 {
-						log("连续5次错误，停止运行")
-						isRunning = false
-						fyne.Do(func() { runButton.SetText("运行") })
-						errList = nil
-						break
-					}
-				} else {
-					recLis = append(recLis, &PriceInfo{time.Now().Unix(), price})
-					if statsNum > 0 {
-						maxVal, minVal, avgVal, medVal := getStatsPrice(recLis, int64(statsNum))
-						log(fmt.Sprintf("当前价格: %.2f|max:%.2f|min:%.2f|avg:%.2f|med:%.2f", price, maxVal, minVal, avgVal, medVal))
-					} else {
-						log(fmt.Sprintf("当前价格: %.2f", price))
-					}
-
-					profit := 10000/price*(price-buyPrice) - 50
-					fyne.Do(func() {
-						currEntry.SetText(fmt.Sprintf("%.2f", price))
-						profitEntry.SetText(fmt.Sprintf("%.2f", profit))
-					})
-
-					errList = append(errList, 0)
-					if len(errList) > 5 {
-						errList = errList[len(errList)-5:]
-					}
+	// 恢复上次的输入，之后修改自动保存
+	settings := loadUISettings(log)
+	for _, v := range views {
+		settings.bindEntry(v.buyPriceEntry, v.name+".buy_price", "buy")
+		settings.bindEntry(v.targetBuyPriceEntry, v.name+".target_buy", "target-buy")
+		settings.bindEntry(v.targetSellPriceEntry, v.name+".target_sell", "target-sell")
+		settings.bindEntry(v.statsEntry, v.name+".stats", "stats")
+		settings.bindEntry(v.dropPctEntry, v.name+".drop_pct", "")
+		settings.bindEntry(v.risePctEntry, v.name+".rise_pct", "")
+		settings.bindEntry(v.stdMultEntry, v.name+".std_mult", "")
+		settings.bindEntry(v.volWindowEntry, v.name+".vol_window", "")
+		settings.bindEntry(v.profitYuanEntry, v.name+".profit_yuan", "")
+		settings.bindEntry(v.profitPctEntry, v.name+".profit_pct", "")
+		settings.bindCheck(v.breakEvenCheck, v.name+".break_even_alert", "")
+	}
+	settings.bindEntry(intervalEntry, "interval", "interval")
+	settings.bindEntry(rearmYuanEntry, "rearm_yuan", "")
+	settings.bindEntry(rearmMinutesEntry, "rearm_minutes", "")
+	settings.bindCheck(keepRunningCheck, "keep_running", "keep")
+	settings.bindCheck(notifyCheck, "notify", "notify")
+	if w, ok := settings.get("window_width", ""); ok {
+		h, _ := settings.get("window_height", "")
+		width, _ := strconv.ParseFloat(w, 32)
+		height, _ := strconv.ParseFloat(h, 32)
+		if width > 0 && height > 0 {
+			myWindow.Resize(fyne.NewSize(float32(width), float32(height)))
+		}
+	}
+	// 输入校验，无效时在表单下方提示并禁用运行按钮（运行中仍可暂停）
+	errLabel := widget.NewLabel("")
+	errLabel.Importance = widget.DangerImportance
+	errLabel.Wrapping = fyne.TextWrapWord
+	errLabel.Hide()
+	updateRunButton := func() {
+		if cancelRun != nil || validator.valid() {
+			runButton.Enable()
+		} else {
+			runButton.Disable()
+		}
+	}
+	validator.onChange = func(err error) {
+		if err != nil {
+			errLabel.SetText(err.Error())
+			errLabel.Show()
+		} else {
+			errLabel.Hide()
+		}
+		updateRunButton()
+	}
+	for _, v := range views {
+		order := targetOrder(v.targetBuyPriceEntry, v.targetSellPriceEntry)
+		validator.add(v.name+" 买入平均价格", v.buyPriceEntry, positive(true, false))
+		validator.add(v.name+" 目标买入价格", v.targetBuyPriceEntry, all(positive(true, false), order))
+		validator.add(v.name+" 目标卖出价格", v.targetSellPriceEntry, all(positive(false, false), order, sellOrProfit(v.profitYuanEntry, v.profitPctEntry)))
+		validator.add(v.name+" 统计时间", v.statsEntry, positive(false, true))
+		validator.add(v.name+" 跌幅提醒", v.dropPctEntry, nonNegative(false))
+		validator.add(v.name+" 涨幅提醒", v.risePctEntry, nonNegative(false))
+		validator.add(v.name+" 波动倍数", v.stdMultEntry, nonNegative(false))
+		validator.add(v.name+" 波动窗口", v.volWindowEntry, positive(false, true))
+		validator.add(v.name+" 目标收益", v.profitYuanEntry, nonNegative(false))
+		validator.add(v.name+" 目标收益率", v.profitPctEntry, nonNegative(false))
+	}
+	validator.add("间隔时间", intervalEntry, between(minIntervalSeconds, maxIntervalSeconds))
+	validator.add("回撤重置", rearmYuanEntry, nonNegative(false))
+	validator.add("冷却时间", rearmMinutesEntry, nonNegative(true))
+	validator.check()
+
+	// 持仓来自账本，交易修改后刷新
+	applyHoldings := func() {
+		for _, v := range views {
+			h, ok := monitor.ledger.get(v.name)
+			v.applyHolding(h, ok)
+			v.updateProfitTarget(h)
+		}
+		chart.refreshLines()
+	}
+	applyHoldings()
 
-					// 仅 interval == 15 时记录
-					go logPriceToDB(price) // 异步写入
-
-					// 买入提醒
-					if price <= targetBuyPrice {
-						msg := fmt.Sprintf("\n买入平均价格: %.2f\n现价: %.2f\n目标买入价格: %.2f\n可以买入！", buyPrice, price, targetBuyPrice)
-						log(msg)
-						if notify && key != "" {
-							go scSend(key, "买入提醒", msg)
-						}
-						showAlertPopup(msg)
-						isRunning = false
-						fyne.Do(func() { runButton.SetText("运行") })
-						break
-					}
+	myWindow.SetOnClosed(func() {
+		size := myWindow.Canvas().Size()
+		settings.set("window_width", fmt.Sprint(size.Width))
+		settings.set("window_height", fmt.Sprint(size.Height))
+		settings.flush()
+	})
 
-					// 卖出提醒
-					if price >= targetSellPrice {
-						msg := fmt.Sprintf("\n买入平均价格: %.2f\n现价: %.2f\n目标卖出价格: %.2f\n可以卖出！", buyPrice, price, targetSellPrice)
-						log(msg)
-						if notify && key != "" {
-							go scSend(key, "卖出提醒", msg)
-						}
-						showAlertPopup(msg)
-						isRunning = false
-						fyne.Do(func() { runButton.SetText("运行") })
-						break
-					}
-				}
-				for i := 0; i < interval; i++ {
-					if isRunning {
-						time.Sleep(time.Second)
-					}
-				}
+	monitor.onQuote = func(name string, quote *PriceQuote, profit, breakEven float64) {
+		chart.onQuote(name, quote)
+		for _, v := range views {
+			if v.name != name {
+				continue
 			}
+			fyne.Do(func() {
+				v.currEntry.SetText(fmt.Sprintf("%.2f / %.2f", quote.Ask, quote.Bid))
+				v.profitEntry.SetText(fmt.Sprintf("%.2f（保本价 %.2f）", profit, breakEven))
+			})
 		}
-	}()
+	}
+	monitor.dispatcher.ResendQueued()
+	startMaintenance(context.Background(), log)
+	startHTTPServer(context.Background(), monitor, log)
 
 	// 运行按钮
 	runButton.OnTapped = func() {
 		buttonMutex.Lock()
 		defer buttonMutex.Unlock()
-		if isRunning {
-			isRunning = false
+		if cancelRun != nil {
+			cancelRun()
+			cancelRun = nil
 			runButton.SetText("运行")
+			updateRunButton()
 			log("已暂停")
 		} else {
-			if buyPriceEntry.Text == "" || targetBuyPriceEntry.Text == "" ||
-				targetSellPriceEntry.Text == "" || intervalEntry.Text == "" {
-				log("请填写所有字段")
+			if !validator.valid() {
+				validator.check()
 				return
 			}
-			isRunning = true
-			stopChan = make(chan struct{})
+			ctx, cancel := context.WithCancel(context.Background())
+			cancelRun = cancel
 			runButton.SetText("暂停")
-			log(fmt.Sprintf("已启动，启用通知:%v", notify))
+			log(fmt.Sprintf("已启动，启用通知:%v，通知渠道:%s", notify, monitor.dispatcher.names()))
+			go func() {
+				monitor.Run(ctx)
+				// 循环自行停止（提醒或连续错误）时恢复按钮
+				fyne.Do(func() {
+					buttonMutex.Lock()
+					defer buttonMutex.Unlock()
+					if ctx.Err() == nil {
+						cancel()
+						cancelRun = nil
+						runButton.SetText("运行")
+						updateRunButton()
+					}
+				})
+			}()
 		}
 	}
 
+	// 规则按钮
+	rulesButton := widget.NewButton("提醒规则", func() {
+		showRulesWindow(myApp, monitor, log)
+	})
+	ledgerButton := widget.NewButton("持仓账本", func() {
+		showLedgerWindow(myApp, monitor, applyHoldings, log)
+	})
+	backtestButton := widget.NewButton("回测", func() {
+		showBacktestWindow(myApp, monitor.settings)
+	})
+	strategyButton := widget.NewButton("策略模拟", func() {
+		showStrategyWindow(myApp)
+	})
+
 	// 布局（无表格）
+	cards := container.NewGridWithColumns(len(views))
+	for _, v := range views {
+		cards.Add(v.card())
+	}
 	form := container.New(layout.NewFormLayout(),
-		widget.NewLabel("买入平均价格："), buyPriceEntry,
-		widget.NewLabel("目标买入价格："), targetBuyPriceEntry,
-		widget.NewLabel("目标卖出价格："), targetSellPriceEntry,
-		widget.NewLabel("当前买卖价格："), currEntry,
-		widget.NewLabel("当前万元收益："), profitEntry,
 		widget.NewLabel("间隔时间（秒）："), intervalEntry,
-		widget.NewLabel("统计时间（分）："), statsEntry,
+		widget.NewLabel("提醒模式："), keepRunningCheck,
+		widget.NewLabel("回撤重置（元）："), rearmYuanEntry,
+		widget.NewLabel("冷却时间（分）："), rearmMinutesEntry,
 		widget.NewLabel("通知设置："), notifyCheck,
 	)
 	// 使用Border布局，让logScroll能够自动扩展
 	topContent := container.NewVBox(
+		cards,
 		form,
-		runButton,
+		errLabel,
+		chart.content,
+		container.NewBorder(nil, nil, nil, container.NewHBox(strategyButton, backtestButton, ledgerButton, rulesButton), runButton),
 		widget.NewLabel("日志："),
 	)
 
@@ -610,3 +988,12 @@ func sum(arr []int) int {
 	}
 	return total
 }
+
+// trailingErrors 末尾连续出错的轮数
+func trailingErrors(arr []int) int {
+	n := 0
+	for i := len(arr) - 1; i >= 0 && arr[i] == 1; i-- {
+		n++
+	}
+	return n
+}
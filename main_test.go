@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// loadTestConfig 在临时目录中写入 conf.ini 并加载，测试结束时恢复原配置
+func loadTestConfig(t *testing.T, conf string) error {
+	t.Helper()
+	old := cfg
+	t.Cleanup(func() { cfg = old })
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("conf.ini", []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return loadConfig()
+}
+
+// 策略品种留空时取第一个品种，不在监控品种中时无论是否启用实时建议都报错
+func TestLoadConfigStrategyInstrument(t *testing.T) {
+	for _, c := range []struct {
+		conf string
+		want string // 为空时应报错
+	}{
+		{"instruments = 建行积存金,工行积存金\n", "建行积存金"},
+		{"instruments = 建行积存金,工行积存金\n[strategy]\ninstrument =\n", "建行积存金"},
+		{"instruments = 建行积存金,工行积存金\n[strategy]\ninstrument = 工行积存金\n", "工行积存金"},
+		{"instruments = 建行积存金\n[strategy]\ninstrument = 工行积存金\n", ""},
+		{"instruments = 建行积存金\n[strategy]\ninstrument = 工行积存金\nlive = true\n", ""},
+	} {
+		err := loadTestConfig(t, c.conf)
+		switch {
+		case c.want == "" && err == nil:
+			t.Errorf("%q: expected error, got instrument %q", c.conf, cfg.Strategy.Instrument)
+		case c.want != "" && err != nil:
+			t.Errorf("%q: %v", c.conf, err)
+		case c.want != "" && cfg.Strategy.Instrument != c.want:
+			t.Errorf("%q: instrument %q, want %q", c.conf, cfg.Strategy.Instrument, c.want)
+		}
+	}
+}
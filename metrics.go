@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------- Prometheus 指标 ---------- */
+
+// metricVec 一组带标签的计数器或仪表，输出为 Prometheus 文本格式
+type metricVec struct {
+	name   string
+	help   string
+	typ    string // counter/gauge
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64 // 标签值以 \xff 连接 -> 值
+}
+
+func newMetricVec(typ, name, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, typ: typ, labels: labels, values: map[string]float64{}}
+}
+
+func (v *metricVec) add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[strings.Join(labelValues, "\xff")] += delta
+}
+
+func (v *metricVec) inc(labelValues ...string) {
+	v.add(1, labelValues...)
+}
+
+func (v *metricVec) set(value float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[strings.Join(labelValues, "\xff")] = value
+}
+
+func (v *metricVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.typ)
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, key, "", ""), formatValue(v.values[key]))
+	}
+}
+
+// histogramVec 带标签的直方图，buckets 为各桶上限（秒）
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // 各桶的累计数量
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, le := range h.buckets {
+		if value <= le {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		for i, le := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatValue(le)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, "", ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, "", ""), s.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels 生成 {a="x",b="y"}，extraName 不为空时追加一个标签（直方图的 le）
+func formatLabels(names []string, key, extraName, extraValue string) string {
+	var pairs []string
+	if len(names) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			if i < len(names) {
+				pairs = append(pairs, names[i]+"="+strconv.Quote(value))
+			}
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+"="+strconv.Quote(extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// 累计指标，由监控循环、通知和数据库写入更新
+var (
+	fetchDuration = newHistogramVec("gold_fetch_duration_seconds", "获取报价耗时（秒）",
+		[]float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20}, "instrument")
+	fetchErrors       = newMetricVec("counter", "gold_fetch_errors_total", "获取报价失败次数", "instrument")
+	notificationsSent = newMetricVec("counter", "gold_notifications_total", "远程通知发送结果（重试后）", "channel", "result")
+	dbWriteFailures   = newMetricVec("counter", "gold_db_write_failures_total", "价格写入数据库失败次数")
+	consecutiveErrors = newMetricVec("gauge", "gold_consecutive_errors", "连续出错的轮数，达到 5 时停止监控")
+	monitorRunning    = newMetricVec("gauge", "gold_monitor_running", "监控循环是否在运行")
+	cumulativeMetrics = []interface{ write(io.Writer) }{fetchDuration, fetchErrors, notificationsSent, dbWriteFailures, consecutiveErrors, monitorRunning}
+	startSeconds      = float64(time.Now().UnixMilli()) / 1000
+)
+
+// initMetrics 启动时把各品种、各渠道的计数器置 0，出错前序列就已存在，increase() 等查询不会漏掉第一次
+func initMetrics(instruments, channels []string) {
+	dbWriteFailures.add(0)
+	for _, name := range instruments {
+		fetchErrors.add(0, name)
+	}
+	for _, ch := range channels {
+		notificationsSent.add(0, ch, "success")
+		notificationsSent.add(0, ch, "failure")
+	}
+}
+
+// handleMetrics 报价、统计和收益取监控循环最近一次的结果，尚未取到价格的品种不输出
+func (s *apiServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	price := newMetricVec("gauge", "gold_price", "最新报价（元/克），type 为 last/bid/ask", "instrument", "type")
+	stats := newMetricVec("gauge", "gold_stats_price", "统计窗口内的价格，stat 为 max/min/avg/median", "instrument", "stat")
+	window := newMetricVec("gauge", "gold_stats_window_minutes", "统计窗口（分）", "instrument")
+	profit := newMetricVec("gauge", "gold_profit_yuan", "扣除卖出手续费后的收益（元）", "instrument")
+	breakEven := newMetricVec("gauge", "gold_break_even_price", "扣除手续费后的保本回购价", "instrument")
+	quoteTime := newMetricVec("gauge", "gold_quote_timestamp_seconds", "最新报价的时间", "instrument")
+	for _, name := range cfg.Instruments {
+		lq, ok := s.monitor.latestQuote(name)
+		if !ok {
+			continue
+		}
+		q := lq.Quote
+		price.set(q.Last, name, "last")
+		price.set(q.Bid, name, "bid")
+		price.set(q.Ask, name, "ask")
+		if lq.Window > 0 {
+			stats.set(lq.Max, name, "max")
+			stats.set(lq.Min, name, "min")
+			stats.set(lq.Avg, name, "avg")
+			stats.set(lq.Med, name, "median")
+			window.set(float64(lq.Window), name)
+		}
+		profit.set(lq.Profit, name)
+		breakEven.set(lq.BreakEven, name)
+		quoteTime.set(float64(q.T.UnixMilli())/1000, name)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, v := range []*metricVec{price, stats, window, profit, breakEven, quoteTime} {
+		v.write(w)
+	}
+	for _, v := range cumulativeMetrics {
+		v.write(w)
+	}
+	fmt.Fprintf(w, "# HELP gold_start_time_seconds 程序启动时间\n# TYPE gold_start_time_seconds gauge\ngold_start_time_seconds %s\n", formatValue(startSeconds))
+}
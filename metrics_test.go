@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// 启动时计数器置 0，已有的计数不被清零
+func TestInitMetrics(t *testing.T) {
+	fetchErrors.inc("浙商积存金")
+	initMetrics([]string{"工行积存金", "浙商积存金"}, []string{"wecom"})
+	var b strings.Builder
+	for _, v := range []*metricVec{fetchErrors, notificationsSent, dbWriteFailures} {
+		v.write(&b)
+	}
+	out := b.String()
+	for _, line := range []string{
+		`gold_fetch_errors_total{instrument="工行积存金"} 0`,
+		`gold_fetch_errors_total{instrument="浙商积存金"} 1`,
+		`gold_notifications_total{channel="wecom",result="success"} 0`,
+		`gold_notifications_total{channel="wecom",result="failure"} 0`,
+		`gold_db_write_failures_total 0`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/* ---------- 数据库版本升级 ---------- */
+
+// migration 一次表结构变更，需可在旧库上重复执行（旧库没有版本记录，会从头执行一遍）
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations 按版本号顺序排列，只能追加，不能修改已发布的版本
+var migrations = []migration{
+	{1, "创建 price_log", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+            CREATE TABLE IF NOT EXISTS price_log (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                ts INTEGER NOT NULL,
+                price REAL NOT NULL
+            );
+        `)
+		return err
+	}},
+	{2, "price_log 增加品种列", func(tx *sql.Tx) error {
+		// 旧库没有品种列，原有数据都属于工行积存金
+		return addColumns(tx, "price_log", "instrument TEXT NOT NULL DEFAULT '工行积存金'")
+	}},
+	{3, "price_log 增加完整报价字段", func(tx *sql.Tx) error {
+		return addColumns(tx, "price_log", "bid REAL", "ask REAL", "open REAL", "high REAL", "low REAL", "chg REAL", "quote_ts INTEGER")
+	}},
+	{4, "创建 notify_queue", func(tx *sql.Tx) error {
+		// 未送达的通知，下次启动时重发
+		_, err := tx.Exec(`
+            CREATE TABLE IF NOT EXISTS notify_queue (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                channel TEXT NOT NULL,
+                title TEXT NOT NULL,
+                content TEXT NOT NULL,
+                created_ts TEXT NOT NULL,
+                attempts INTEGER NOT NULL DEFAULT 0,
+                last_error TEXT
+            );
+        `)
+		return err
+	}},
+	{5, "创建 alert_rule", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+            CREATE TABLE IF NOT EXISTS alert_rule (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                name TEXT NOT NULL,
+                instrument TEXT NOT NULL DEFAULT '',
+                expr TEXT NOT NULL,
+                channels TEXT NOT NULL DEFAULT '',
+                cooldown INTEGER NOT NULL DEFAULT 30,
+                enabled INTEGER NOT NULL DEFAULT 1
+            );
+        `)
+		return err
+	}},
+	{6, "price_log.ts 改为毫秒整数", migratePriceLogTs},
+	{7, "price_log 按品种和时间建索引", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_price_log_instrument_ts ON price_log(instrument, ts)`)
+		return err
+	}},
+	{8, "创建 1分钟/1小时/日K线汇总表", func(tx *sql.Tx) error {
+		for _, table := range []string{"price_1m", "price_1h", "price_1d"} {
+			_, err := tx.Exec(fmt.Sprintf(`
+            CREATE TABLE IF NOT EXISTS %s (
+                instrument TEXT NOT NULL,
+                ts INTEGER NOT NULL,
+                open REAL NOT NULL,
+                high REAL NOT NULL,
+                low REAL NOT NULL,
+                close REAL NOT NULL,
+                count INTEGER NOT NULL,
+                PRIMARY KEY (instrument, ts)
+            ) WITHOUT ROWID;
+        `, table))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{9, "创建 ui_setting", func(tx *sql.Tx) error {
+		// 界面输入值，下次启动时恢复
+		_, err := tx.Exec(`
+            CREATE TABLE IF NOT EXISTS ui_setting (
+                key TEXT PRIMARY KEY,
+                value TEXT NOT NULL
+            );
+        `)
+		return err
+	}},
+	{10, "创建 trade", func(tx *sql.Tx) error {
+		// 持仓账本，side 为 buy/sell
+		_, err := tx.Exec(`
+            CREATE TABLE IF NOT EXISTS trade (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                instrument TEXT NOT NULL,
+                ts INTEGER NOT NULL,
+                side TEXT NOT NULL CHECK (side IN ('buy', 'sell')),
+                grams REAL NOT NULL,
+                price REAL NOT NULL,
+                fee REAL NOT NULL DEFAULT 0
+            );
+        `)
+		return err
+	}},
+}
+
+// migrateDB 依次执行未应用的版本，每个版本一个事务，执行前先备份数据库
+func migrateDB() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_version (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at INTEGER NOT NULL
+        );
+    `)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&current); err != nil {
+		return err
+	}
+	latest := migrations[len(migrations)-1].version
+	if current > latest {
+		return fmt.Errorf("数据库版本 %d 高于程序支持的版本 %d，请升级程序", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if err := backupDB(current); err != nil {
+		return fmt.Errorf("升级前备份数据库失败: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(m); err != nil {
+			return fmt.Errorf("数据库升级到版本 %d（%s）失败: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO schema_version(version, name, applied_at) VALUES(?, ?, ?)`,
+		m.version, m.name, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// backupDB 用 VACUUM INTO 生成一致的副本，WAL 中未合并的数据也会包含在内。新建的空库不备份
+func backupDB(version int) error {
+	var tables int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_version', 'sqlite_sequence')`).Scan(&tables)
+	if err != nil {
+		return err
+	}
+	if tables == 0 {
+		return nil
+	}
+
+	path := fmt.Sprintf("%s.v%d-%s.bak", cfg.SqlitePath, version, time.Now().Format("20060102150405"))
+	_, err = db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// addColumns 列不存在时才添加，col 为完整的列定义
+func addColumns(tx *sql.Tx, table string, cols ...string) error {
+	for _, col := range cols {
+		name := strings.Fields(col)[0]
+		ok, err := hasColumn(tx, table, name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, col)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratePriceLogTs 把 RFC3339 文本时间转换为 UTC 毫秒整数，文本比较在时区变化时顺序会乱
+func migratePriceLogTs(tx *sql.Tx) error {
+	typ, err := columnType(tx, "price_log", "ts")
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(typ, "TEXT") {
+		return nil
+	}
+
+	stmts := []string{
+		`CREATE TABLE price_log_new (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            ts INTEGER NOT NULL,
+            instrument TEXT NOT NULL DEFAULT '工行积存金',
+            price REAL NOT NULL,
+            bid REAL,
+            ask REAL,
+            open REAL,
+            high REAL,
+            low REAL,
+            chg REAL,
+            quote_ts INTEGER
+        )`,
+		// strftime 会按时间中的时区偏移换算成 UTC，无法解析的行丢弃
+		`INSERT INTO price_log_new(id, ts, instrument, price, bid, ask, open, high, low, chg, quote_ts)
+            SELECT id, CAST(strftime('%s', ts) AS INTEGER) * 1000, instrument, price, bid, ask, open, high, low, chg, quote_ts
+            FROM price_log
+            WHERE strftime('%s', ts) IS NOT NULL`,
+		`DROP TABLE price_log`,
+		`ALTER TABLE price_log_new RENAME TO price_log`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 判断表中是否存在某列
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	typ, err := columnType(tx, table, column)
+	return typ != "", err
+}
+
+// 列的声明类型，列不存在时返回空
+func columnType(tx *sql.Tx, table, column string) (string, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return "", err
+		}
+		if name == column {
+			return typ, nil
+		}
+	}
+	return "", rows.Err()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createBaselineDB 建立没有版本记录的旧库：只有 price_log，时间为 RFC3339 文本
+func createBaselineDB(t *testing.T, path string) {
+	t.Helper()
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE price_log (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            ts TEXT NOT NULL,
+            price REAL NOT NULL
+        )`,
+		`INSERT INTO price_log(ts, price) VALUES
+            ('2024-01-02T08:00:00+08:00', 480.5),
+            ('2024-01-02T00:01:00Z', 481),
+            ('不是时间', 482)`,
+	} {
+		if _, err := old.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func schemaVersion(t *testing.T) int {
+	t.Helper()
+	var v, n int
+	if err := db.QueryRow(`SELECT MAX(version), COUNT(*) FROM schema_version`).Scan(&v, &n); err != nil {
+		t.Fatal(err)
+	}
+	if v != n {
+		t.Errorf("schema_version 有 %d 条记录，最高版本 %d", n, v)
+	}
+	return v
+}
+
+func TestMigrateBaselineDB(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gold_price.db")
+	createBaselineDB(t, path)
+	openTestDBAt(t, path)
+
+	latest := migrations[len(migrations)-1].version
+	if v := schemaVersion(t); v != latest {
+		t.Errorf("version = %d, want %d", v, latest)
+	}
+
+	// 文本时间换算为 UTC 毫秒，原有数据归入工行积存金，无法解析的行丢弃
+	rows, err := db.Query(`SELECT id, ts, instrument, price, typeof(ts) FROM price_log ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type row struct {
+		id         int64
+		ts         int64
+		instrument string
+		price      float64
+		typ        string
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.ts, &r.instrument, &r.price, &r.typ); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []row{
+		{1, 1704153600000, "工行积存金", 480.5, "integer"},
+		{2, 1704153660000, "工行积存金", 481, "integer"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("price_log = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// 升级后的表可以正常写入
+	if _, err := insertStmt.Exec(1704153720000, "工行积存金", 482, 481, 483, nil, nil, nil, nil, nil); err != nil {
+		t.Errorf("insert: %v", err)
+	}
+	for _, table := range []string{"notify_queue", "alert_rule", "price_1m", "price_1h", "price_1d", "ui_setting", "trade"} {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n); err != nil || n != 1 {
+			t.Errorf("缺少表 %s: %v", table, err)
+		}
+	}
+
+	// 升级前的备份保留旧表结构和全部数据
+	baks, err := filepath.Glob(path + ".v0-*.bak")
+	if err != nil || len(baks) != 1 {
+		t.Fatalf("backups = %v, %v", baks, err)
+	}
+	bak, err := sql.Open("sqlite", baks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bak.Close()
+	var n int
+	var typ string
+	if err := bak.QueryRow(`SELECT COUNT(*), MIN(typeof(ts)) FROM price_log`).Scan(&n, &typ); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || typ != "text" {
+		t.Errorf("backup price_log = %d rows of %s, want 3 rows of text", n, typ)
+	}
+
+	// 已是最新版本时不再升级也不再备份
+	if err := migrateDB(); err != nil {
+		t.Fatal(err)
+	}
+	if v := schemaVersion(t); v != latest {
+		t.Errorf("version after rerun = %d", v)
+	}
+	if baks, _ := filepath.Glob(path + ".v*.bak"); len(baks) != 1 {
+		t.Errorf("backups after rerun = %v", baks)
+	}
+}
+
+// 新库没有数据，不需要备份
+func TestMigrateNewDBWithoutBackup(t *testing.T) {
+	openTestDB(t)
+	if v := schemaVersion(t); v != migrations[len(migrations)-1].version {
+		t.Errorf("version = %d", v)
+	}
+	if baks, _ := filepath.Glob(cfg.SqlitePath + ".v*.bak"); len(baks) != 0 {
+		t.Errorf("unexpected backups %v", baks)
+	}
+}
+
+// 数据库版本高于程序时拒绝启动，避免旧程序改坏新库
+func TestMigrateRejectsNewerDB(t *testing.T) {
+	openTestDB(t)
+	if _, err := db.Exec(`INSERT INTO schema_version(version, name, applied_at) VALUES(99, '未来版本', 0)`); err != nil {
+		t.Fatal(err)
+	}
+	err := migrateDB()
+	if err == nil || !strings.Contains(err.Error(), "请升级程序") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+/* ---------- 监控循环 ---------- */
+
+// Target 单个品种的提醒参数
+type Target struct {
+	BuyPrice        float64 // 买入平均价格
+	TargetBuyPrice  float64 // 目标买入价格
+	TargetSellPrice float64 // 目标卖出价格
+	StatsMinutes    int     // 统计时间（分）
+	DropPct         float64 // 波动窗口内从最高点下跌百分比，0 表示不提醒
+	RisePct         float64 // 波动窗口内从最低点上涨百分比，0 表示不提醒
+	StdMult         float64 // 偏离窗口均值超过几倍标准差，0 表示不提醒
+	VolWindow       int     // 波动窗口（分）
+	ProfitYuan      float64 // 扣费后净收益达到多少元提醒，0 表示不提醒
+	ProfitPct       float64 // 扣费后收益率达到多少百分比提醒，0 表示不提醒
+	BreakEvenAlert  bool    // 回购价回到保本价以上时提醒
+}
+
+// profitTarget 收益目标对应的回购价，元和百分比都设置时取先达到的较低价格，未设置时返回 0
+func (t Target) profitTarget(grams, cost float64, fs Fees) float64 {
+	var price float64
+	for _, goal := range []float64{t.ProfitYuan, cost * t.ProfitPct / 100} {
+		if goal <= 0 {
+			continue
+		}
+		if p := fs.targetPrice(grams, cost, goal); p > 0 && (price == 0 || p < price) {
+			price = p
+		}
+	}
+	return price
+}
+
+// check 目标买入价必填；目标卖出价可以不设置，此时需设置收益目标
+func (t Target) check() error {
+	if t.TargetBuyPrice <= 0 {
+		return fmt.Errorf("未设置目标买入价")
+	}
+	if t.TargetSellPrice <= 0 {
+		if t.ProfitYuan <= 0 && t.ProfitPct <= 0 {
+			return fmt.Errorf("未设置目标卖出价或收益目标")
+		}
+		return nil
+	}
+	if t.TargetBuyPrice >= t.TargetSellPrice {
+		return fmt.Errorf("目标买入价需低于目标卖出价")
+	}
+	return nil
+}
+
+// RearmPolicy 持续监控时提醒的重新启用条件，满足任一即可
+type RearmPolicy struct {
+	Yuan    float64 // 价格反向回到目标价之外多少元
+	Minutes int     // 距上次提醒多少分钟，0 表示不按时间
+}
+
+// MonitorSettings 每轮读取一次，界面模式来自输入框，后台模式来自配置
+type MonitorSettings struct {
+	Interval    int               // 间隔时间（秒）
+	KeepRunning bool              // 提醒后继续监控，否则停止
+	Rearm       RearmPolicy       // 继续监控时的冷却条件
+	Targets     map[string]Target // 品种名称 -> 提醒参数
+}
+
+// configSettings 配置文件与命令行中的参数
+func configSettings() *MonitorSettings {
+	return &MonitorSettings{
+		Interval:    cfg.Interval,
+		KeepRunning: cfg.KeepRunning,
+		Rearm:       cfg.Rearm,
+		Targets:     cfg.Targets,
+	}
+}
+
+// alertLatch 提醒触发后锁定，满足 RearmPolicy 后重新启用
+type alertLatch struct {
+	fired   bool
+	firedAt time.Time
+}
+
+// update hit 为本次是否达到条件，distance 为价格离开目标价的距离（元）。返回是否应当提醒
+func (l *alertLatch) update(hit bool, distance float64, now time.Time, p RearmPolicy) (fire, rearmed bool) {
+	if l.fired {
+		if (!hit && distance >= p.Yuan) ||
+			(p.Minutes > 0 && now.Sub(l.firedAt) >= time.Duration(p.Minutes)*time.Minute) {
+			l.fired = false
+			rearmed = true
+		}
+	}
+	if hit && !l.fired {
+		l.fired = true
+		l.firedAt = now
+		fire = true
+	}
+	return
+}
+
+type monitoredInstrument struct {
+	source    PriceSource
+	recLis    []*PriceInfo
+	buyLatch  alertLatch
+	sellLatch alertLatch
+	dropLatch alertLatch
+	riseLatch alertLatch
+	volLatch  alertLatch
+
+	profitLatch    alertLatch
+	belowBreakEven bool // 上次回购价低于保本价，回到保本价以上时提醒
+}
+
+// Monitor 抓取价格、统计并提醒，与界面无关
+type Monitor struct {
+	instruments []*monitoredInstrument
+	settings    func() (*MonitorSettings, error)
+	log         func(string)
+	popup       Popup                                                           // 本地提醒
+	dispatcher  *Dispatcher                                                     // 远程通知
+	rules       *ruleSet                                                        // 自定义提醒规则
+	ledger      *ledger                                                         // 持仓账本
+	strategy    *strategyState                                                  // 实时策略建议，可为空
+	fees        func(name string) Fees                                          // 品种手续费
+	clock       func() time.Time                                                // 当前时间，回测时为回放到的记录时间
+	save        func(name string, quote *PriceQuote)                            // 保存报价，回测时为空
+	onAlert     func(name, title string)                                        // 触发提醒后回调，可为空
+	onQuote     func(name string, quote *PriceQuote, profit, breakEven float64) // 取到价格后回调，可为空
+
+	runMu   sync.Mutex // 同一时间只运行一个循环
+	errList []int
+
+	stateMu sync.Mutex // 保护以下供 HTTP 接口读取的状态
+	latest  map[string]*liveQuote
+	current *MonitorSettings // 最近一轮使用的参数
+	events  *eventHub        // 推送给 HTTP 连接的报价和提醒，回测时为空
+}
+
+// liveQuote 品种最近一次取到的报价、统计窗口内的价格及按当时持仓计算的收益
+type liveQuote struct {
+	Quote     *PriceQuote
+	Profit    float64
+	BreakEven float64
+	Window    int // 统计时间（分），为 0 时没有统计值
+	Max       float64
+	Min       float64
+	Avg       float64
+	Med       float64
+}
+
+func newMonitor(sources []PriceSource, settings func() (*MonitorSettings, error), log func(string)) *Monitor {
+	m := &Monitor{
+		settings:   settings,
+		log:        log,
+		popup:      noopPopup{},
+		dispatcher: newDispatcher(buildNotifiers(), log),
+		rules:      newRuleSet(),
+		ledger:     newLedger(),
+		fees:       feesFor,
+		clock:      time.Now,
+		save:       logPriceToDB,
+		latest:     map[string]*liveQuote{},
+		events:     newEventHub(),
+	}
+	initMetrics(cfg.Instruments, m.dispatcher.channelNames())
+	if err := m.rules.reload(); err != nil {
+		log(fmt.Sprintf("加载提醒规则失败: %v", err))
+	}
+	if err := m.ledger.reload(); err != nil {
+		log(fmt.Sprintf("加载持仓失败: %v", err))
+	}
+	if cfg.Strategy.Live {
+		if s, err := newLiveStrategy(cfg.Strategy); err != nil {
+			log(fmt.Sprintf("启用策略建议失败: %v", err))
+		} else {
+			m.strategy = s
+			log(fmt.Sprintf("已启用策略建议：%s %s", cfg.Strategy.Instrument, cfg.Strategy.describe()))
+		}
+	}
+	for _, src := range sources {
+		m.instruments = append(m.instruments, &monitoredInstrument{
+			source: src,
+			recLis: getRecentPriceData(src.Name(), time.Now().Add(-12*time.Hour)),
+		})
+	}
+	return m
+}
+
+// Run 运行到 ctx 取消、连续出错或触发提醒为止
+func (m *Monitor) Run(ctx context.Context) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	monitorRunning.set(1)
+	defer monitorRunning.set(0)
+
+	// 每次启动重新启用所有提醒
+	for _, ins := range m.instruments {
+		ins.buyLatch = alertLatch{}
+		ins.sellLatch = alertLatch{}
+		ins.dropLatch = alertLatch{}
+		ins.riseLatch = alertLatch{}
+		ins.volLatch = alertLatch{}
+		ins.profitLatch = alertLatch{}
+		ins.belowBreakEven = false
+	}
+
+	for ctx.Err() == nil {
+		st, err := m.settings()
+		if err != nil {
+			m.log(err.Error())
+			sleepCtx(ctx, time.Second)
+			continue
+		}
+		m.stateMu.Lock()
+		m.current = st
+		m.stateMu.Unlock()
+
+		failed, alerted := m.poll(ctx, st)
+
+		// 任一品种请求失败都计为一次错误
+		if failed {
+			m.errList = append(m.errList, 1)
+		} else {
+			m.errList = append(m.errList, 0)
+		}
+		if len(m.errList) > 5 {
+			m.errList = m.errList[len(m.errList)-5:]
+		}
+		consecutiveErrors.set(float64(trailingErrors(m.errList)))
+		if len(m.errList) == 5 && sum(m.errList) == 5 {
+			m.log("连续5次错误，停止运行")
+			m.errList = nil
+			return
+		}
+
+		// 本轮有提醒且未开启持续监控则停止
+		if alerted && !st.KeepRunning {
+			return
+		}
+
+		sleepCtx(ctx, time.Duration(st.Interval)*time.Second)
+	}
+}
+
+// poll 抓取所有品种一次
+func (m *Monitor) poll(ctx context.Context, st *MonitorSettings) (failed, alerted bool) {
+	for _, ins := range m.instruments {
+		if ctx.Err() != nil {
+			return
+		}
+		name := ins.source.Name()
+		target := st.Targets[name]
+		if target.StatsMinutes <= 0 {
+			m.log(name + " 统计时间无效")
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+		start := time.Now()
+		quote, err := ins.source.Fetch(fetchCtx)
+		fetchDuration.observe(time.Since(start).Seconds(), name)
+		cancel()
+		if err != nil {
+			fetchErrors.inc(name)
+			m.log(fmt.Sprintf("%s 错误: %v", name, err))
+			failed = true
+			continue
+		}
+
+		if m.check(ins, st, quote) {
+			alerted = true
+		}
+	}
+	return
+}
+
+// check 记录一次报价并判断是否提醒
+func (m *Monitor) check(ins *monitoredInstrument, st *MonitorSettings, quote *PriceQuote) bool {
+	name := ins.source.Name()
+	target := st.Targets[name]
+	price := quote.Last
+	now := m.clock()
+	// 按取到报价的时间记录，与数据库中的 ts 一致；休市时报价时间停在收盘，按它截取窗口会取不到记录
+	ins.recLis = append(ins.recLis, &PriceInfo{now.Unix(), price, quote.Bid, quote.Ask})
+	live := &liveQuote{Quote: quote}
+	if target.StatsMinutes > 0 {
+		maxVal, minVal, avgVal, medVal := getStatsPrice(ins.recLis, int64(target.StatsMinutes), now.Unix())
+		live.Window, live.Max, live.Min, live.Avg, live.Med = target.StatsMinutes, maxVal, minVal, avgVal, medVal
+		m.log(fmt.Sprintf("%s 当前价格: %.2f|买入:%.2f|回购:%.2f|max:%.2f|min:%.2f|avg:%.2f|med:%.2f", name, price, quote.Ask, quote.Bid, maxVal, minVal, avgVal, medVal))
+	} else {
+		m.log(fmt.Sprintf("%s 当前价格: %.2f|买入:%.2f|回购:%.2f", name, price, quote.Ask, quote.Bid))
+	}
+
+	// 卖出按回购价成交，收益和保本价都扣除卖出手续费。有持仓时成本取账本，否则按本金估算
+	fees := m.fees(name)
+	holding, _ := m.ledger.get(name)
+	grams, cost := fees.position(target.BuyPrice, holding)
+	if holding.Grams > 0 {
+		target.BuyPrice = holding.AvgCost
+	}
+	profit := fees.profit(grams, cost, quote.Bid)
+	breakEven := fees.breakEven(grams, cost)
+	profitTarget := target.profitTarget(grams, cost, fees)
+	m.stateMu.Lock()
+	live.Profit, live.BreakEven = profit, breakEven
+	m.latest[name] = live
+	m.stateMu.Unlock()
+	if m.events != nil {
+		m.events.publish("quote", name, streamQuote{newQuoteJSON(name, quote), profit, breakEven})
+	}
+	if m.onQuote != nil {
+		m.onQuote(name, quote, profit, breakEven)
+	}
+
+	// 异步写入
+	if m.save != nil {
+		go m.save(name, quote)
+	}
+
+	alerted := false
+
+	// 买入提醒，按买入价判断
+	fire, rearmed := ins.buyLatch.update(quote.Ask <= target.TargetBuyPrice, quote.Ask-target.TargetBuyPrice, now, st.Rearm)
+	if rearmed {
+		m.log(name + " 买入提醒已重新启用")
+	}
+	if fire {
+		msg := fmt.Sprintf("\n品种: %s\n买入平均价格: %.2f\n买入价: %.2f\n目标买入价格: %.2f\n可以买入！", name, target.BuyPrice, quote.Ask, target.TargetBuyPrice)
+		m.alert(name, "买入提醒", msg)
+		alerted = true
+	}
+
+	// 卖出提醒，按回购价判断，目标价低于扣费后的保本价时以保本价为准。
+	// 未设置目标卖出价时只按收益目标提醒
+	if target.TargetSellPrice > 0 {
+		sellAt := max(target.TargetSellPrice, breakEven)
+		fire, rearmed = ins.sellLatch.update(quote.Bid >= sellAt, sellAt-quote.Bid, now, st.Rearm)
+		if rearmed {
+			m.log(name + " 卖出提醒已重新启用")
+		}
+		if fire {
+			msg := fmt.Sprintf("\n品种: %s\n买入平均价格: %.2f\n回购价: %.2f\n目标卖出价格: %.2f\n保本价格: %.2f\n扣费后收益: %.2f\n可以卖出！", name, target.BuyPrice, quote.Bid, target.TargetSellPrice, breakEven, profit)
+			m.alert(name, "卖出提醒", msg)
+			alerted = true
+		}
+	}
+
+	// 收益目标提醒，目标价由持仓成本和手续费推算
+	if profitTarget > 0 {
+		fire, rearmed = ins.profitLatch.update(quote.Bid >= profitTarget, profitTarget-quote.Bid, now, st.Rearm)
+		if rearmed {
+			m.log(name + " 收益目标提醒已重新启用")
+		}
+		if fire {
+			msg := fmt.Sprintf("\n品种: %s\n持仓成本: %.2f\n回购价: %.2f\n收益目标对应价格: %.2f\n扣费后收益: %.2f（%.2f%%）\n已达到收益目标！", name, cost, quote.Bid, profitTarget, profit, profit/cost*100)
+			m.alert(name, "收益目标提醒", msg)
+			alerted = true
+		}
+	}
+
+	// 回本提醒，只在回购价从保本价以下回升时提醒
+	if target.BreakEvenAlert && breakEven > 0 {
+		if quote.Bid < breakEven {
+			ins.belowBreakEven = true
+		} else if ins.belowBreakEven {
+			ins.belowBreakEven = false
+			msg := fmt.Sprintf("\n品种: %s\n回购价: %.2f\n保本价格: %.2f\n扣费后收益: %.2f\n已回本！", name, quote.Bid, breakEven, profit)
+			m.alert(name, "回本提醒", msg)
+			alerted = true
+		}
+	}
+
+	if m.checkVolatility(ins, st, price, now) {
+		alerted = true
+	}
+
+	// 策略建议按虚拟持仓给出，不影响停止/继续监控
+	if s := m.strategy; s != nil && s.s.Instrument == name {
+		if trades := s.onTick(quote); len(trades) > 0 {
+			m.alert(name, "策略建议", strategyMessage(s.s, trades))
+		}
+	}
+
+	// 自定义规则各自冷却，不影响停止/继续监控
+	env := func(window int) map[string]float64 {
+		if window <= 0 {
+			window = target.StatsMinutes
+		}
+		vars := map[string]float64{
+			"price":         price,
+			"bid":           quote.Bid,
+			"ask":           quote.Ask,
+			"open":          quote.Open,
+			"high":          quote.High,
+			"low":           quote.Low,
+			"change":        quote.Change,
+			"buy":           target.BuyPrice,
+			"profit":        profit,
+			"grams":         holding.Grams,
+			"realized":      holding.Realized,
+			"unrealized":    fees.profit(holding.Grams, holding.Grams*holding.AvgCost, quote.Bid),
+			"break_even":    breakEven,
+			"profit_target": profitTarget,
+			"target_buy":    target.TargetBuyPrice,
+			"target_sell":   target.TargetSellPrice,
+		}
+		if window > 0 {
+			maxVal, minVal, avgVal, medVal := getStatsPrice(ins.recLis, int64(window), now.Unix())
+			if maxVal <= 0 || minVal <= 0 {
+				// 窗口内没有记录，不提供统计变量，用到它们的规则不提醒
+				return vars
+			}
+			stdVal, firstVal := getStdPrice(ins.recLis, int64(window), now.Unix())
+			vars["max"], vars["min"], vars["avg"], vars["med"], vars["std"] = maxVal, minVal, avgVal, medVal, stdVal
+			vars["drop_pct"] = (maxVal - price) / maxVal * 100
+			vars["rise_pct"] = (price - minVal) / minVal * 100
+			if firstVal > 0 {
+				vars["pct"] = (price - firstVal) / firstVal * 100
+			}
+		}
+		return vars
+	}
+	for _, r := range m.rules.match(name, now, env) {
+		msg := fmt.Sprintf("\n品种: %s\n规则: %s\n条件: %s\n最新价: %.2f\n买入价: %.2f\n回购价: %.2f", name, r.Name, r.Expr, price, quote.Ask, quote.Bid)
+		m.alertTo(name, r.channelList(), "规则提醒："+r.Name, msg)
+	}
+	return alerted
+}
+
+// checkVolatility 涨跌幅与标准差提醒，统计窗口为 VolWindow
+func (m *Monitor) checkVolatility(ins *monitoredInstrument, st *MonitorSettings, price float64, now time.Time) bool {
+	target := st.Targets[ins.source.Name()]
+	if target.VolWindow <= 0 || (target.DropPct <= 0 && target.RisePct <= 0 && target.StdMult <= 0) {
+		return false
+	}
+	name := ins.source.Name()
+	window := int64(target.VolWindow)
+	maxVal, minVal, avgVal, _ := getStatsPrice(ins.recLis, window, now.Unix())
+	if maxVal <= 0 || minVal <= 0 {
+		// 窗口内没有记录
+		return false
+	}
+	alerted := false
+
+	// 从窗口最高点下跌，distance 为价格高出触发线多少元
+	if target.DropPct > 0 {
+		line := maxVal * (1 - target.DropPct/100)
+		fire, rearmed := ins.dropLatch.update(price <= line, price-line, now, st.Rearm)
+		if rearmed {
+			m.log(name + " 跌幅提醒已重新启用")
+		}
+		if fire {
+			msg := fmt.Sprintf("\n品种: %s\n%d分钟内最高: %.2f\n现价: %.2f\n跌幅: %.2f%%\n超过跌幅提醒 %.2f%%！", name, target.VolWindow, maxVal, price, (maxVal-price)/maxVal*100, target.DropPct)
+			m.alert(name, "跌幅提醒", msg)
+			alerted = true
+		}
+	}
+
+	// 从窗口最低点上涨
+	if target.RisePct > 0 {
+		line := minVal * (1 + target.RisePct/100)
+		fire, rearmed := ins.riseLatch.update(price >= line, line-price, now, st.Rearm)
+		if rearmed {
+			m.log(name + " 涨幅提醒已重新启用")
+		}
+		if fire {
+			msg := fmt.Sprintf("\n品种: %s\n%d分钟内最低: %.2f\n现价: %.2f\n涨幅: %.2f%%\n超过涨幅提醒 %.2f%%！", name, target.VolWindow, minVal, price, (price-minVal)/minVal*100, target.RisePct)
+			m.alert(name, "涨幅提醒", msg)
+			alerted = true
+		}
+	}
+
+	// 偏离均值超过 N 倍标准差，样本太少时标准差为 0 不提醒
+	if target.StdMult > 0 {
+		stdVal, _ := getStdPrice(ins.recLis, window, now.Unix())
+		if stdVal > 0 {
+			limit := target.StdMult * stdVal
+			move := math.Abs(price - avgVal)
+			fire, rearmed := ins.volLatch.update(move > limit, limit-move, now, st.Rearm)
+			if rearmed {
+				m.log(name + " 波动提醒已重新启用")
+			}
+			if fire {
+				msg := fmt.Sprintf("\n品种: %s\n%d分钟均价: %.2f\n标准差: %.2f\n现价: %.2f\n偏离 %.2f 元，超过 %.1f 倍标准差！", name, target.VolWindow, avgVal, stdVal, price, price-avgVal, target.StdMult)
+				m.alert(name, "波动提醒", msg)
+				alerted = true
+			}
+		}
+	}
+	return alerted
+}
+
+// latestQuote 品种最近一次取到的报价，启动后尚未取到时 ok 为 false
+func (m *Monitor) latestQuote(name string) (*liveQuote, bool) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	q, ok := m.latest[name]
+	return q, ok
+}
+
+// currentSettings 最近一轮使用的参数，尚未运行时取配置文件中的参数。
+// 不调用 m.settings：界面模式下它读取输入框，只能在监控循环中调用
+func (m *Monitor) currentSettings() *MonitorSettings {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if m.current != nil {
+		return m.current
+	}
+	return configSettings()
+}
+
+func (m *Monitor) alert(name, title, msg string) {
+	m.alertTo(name, nil, title, msg)
+}
+
+// alertTo channels 为空时发送到所有渠道
+func (m *Monitor) alertTo(name string, channels []string, title, msg string) {
+	if m.onAlert != nil {
+		m.onAlert(name, title)
+	}
+	if m.events != nil {
+		m.events.publish("alert", name, newStreamAlert(name, title, msg, m.clock()))
+	}
+	m.log(msg)
+	if notify {
+		m.dispatcher.DispatchTo(channels, title, msg)
+	}
+	m.popup.Show(title, msg)
+}
+
+// sleepCtx 等待 d，ctx 取消时提前返回
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
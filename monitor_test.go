@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeSource 按顺序返回预设的报价，nil 表示请求失败，用完后一直失败
+type fakeSource struct {
+	name   string
+	quotes []*PriceQuote
+}
+
+func (s *fakeSource) Name() string { return s.name }
+
+func (s *fakeSource) Fetch(context.Context) (*PriceQuote, error) {
+	if len(s.quotes) == 0 || s.quotes[0] == nil {
+		if len(s.quotes) > 0 {
+			s.quotes = s.quotes[1:]
+		}
+		return nil, errors.New("请求失败")
+	}
+	q := s.quotes[0]
+	s.quotes = s.quotes[1:]
+	return q, nil
+}
+
+// staleTime 休市时接口返回的报价时间停在收盘
+var staleTime = time.Date(2026, 10, 16, 2, 30, 0, 0, time.Local)
+
+// quote 回购价和买入价分别比最新价低、高 1 元
+func quote(last float64) *PriceQuote {
+	return &PriceQuote{Last: last, Bid: last - 1, Ask: last + 1, T: staleTime}
+}
+
+// testMonitor 时间由测试控制，提醒记录在 alerts 中，不写数据库、不弹窗、不发通知
+type testMonitor struct {
+	*Monitor
+	src    *fakeSource
+	now    time.Time
+	alerts []string
+}
+
+func newTestMonitor() *testMonitor {
+	tm := &testMonitor{
+		src: &fakeSource{name: "工行积存金"},
+		now: time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local),
+	}
+	tm.Monitor = &Monitor{
+		instruments: []*monitoredInstrument{{source: tm.src}},
+		log:         func(string) {},
+		popup:       noopPopup{},
+		dispatcher:  newDispatcher(nil, func(string) {}),
+		rules:       newRuleSet(),
+		ledger:      newLedger(),
+		fees:        func(string) Fees { return defaultFees },
+		clock:       func() time.Time { return tm.now },
+		latest:      map[string]*liveQuote{},
+	}
+	tm.onAlert = func(name, title string) { tm.alerts = append(tm.alerts, title) }
+	return tm
+}
+
+// tick 时间前进 d 后按 last 抓取一轮，返回本轮的提醒
+func (tm *testMonitor) tick(st *MonitorSettings, d time.Duration, last float64) []string {
+	tm.now = tm.now.Add(d)
+	tm.src.quotes = append(tm.src.quotes, quote(last))
+	n := len(tm.alerts)
+	tm.poll(context.Background(), st)
+	return tm.alerts[n:]
+}
+
+func settingsFor(t Target, rearm RearmPolicy) *MonitorSettings {
+	return &MonitorSettings{KeepRunning: true, Rearm: rearm, Targets: map[string]Target{"工行积存金": t}}
+}
+
+type step struct {
+	after time.Duration
+	last  float64
+	want  []string
+}
+
+func runSteps(t *testing.T, tm *testMonitor, st *MonitorSettings, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		if got := tm.tick(st, s.after, s.last); !slices.Equal(got, s.want) {
+			t.Errorf("step %d (last %.2f): got %v, want %v", i, s.last, got, s.want)
+		}
+	}
+}
+
+// 买入按买入价判断，卖出按回购价判断，统计按最新价
+func TestCheckAlerts(t *testing.T) {
+	st := settingsFor(Target{BuyPrice: 520, TargetBuyPrice: 500, TargetSellPrice: 600, StatsMinutes: 10}, RearmPolicy{})
+	for _, c := range []struct {
+		last float64
+		want bool
+	}{
+		{550, false},
+		{500, false}, // 买入价 501
+		{499, true},  // 买入价 500
+		{480, true},
+		{600, false}, // 回购价 599
+		{601, true},  // 回购价 600
+	} {
+		tm := newTestMonitor()
+		if got := tm.tick(st, time.Minute, c.last); (len(got) > 0) != c.want {
+			t.Errorf("%.2f: alerts %v, want %v", c.last, got, c.want)
+		}
+		if recLis := tm.instruments[0].recLis; len(recLis) != 1 || recLis[0].Price != c.last || recLis[0].Bid != c.last-1 || recLis[0].Ask != c.last+1 {
+			t.Errorf("%.2f: recLis %v", c.last, recLis)
+		}
+	}
+}
+
+// 连续 5 轮请求失败后停止
+func TestRunStopsAfterConsecutiveErrors(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{TargetBuyPrice: 400, TargetSellPrice: 600, StatsMinutes: 10}, RearmPolicy{})
+	tm.settings = func() (*MonitorSettings, error) { return st, nil }
+	tm.src.quotes = []*PriceQuote{quote(520), nil, nil, nil, quote(520), nil, nil, nil, nil, nil, quote(520)}
+
+	done := make(chan struct{})
+	go func() {
+		tm.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop")
+	}
+	// 第 5 到第 9 轮连续失败，最后一条报价不会被取走
+	if len(tm.src.quotes) != 1 {
+		t.Errorf("%d quotes left, want 1", len(tm.src.quotes))
+	}
+}
+
+// 触发提醒后停止
+func TestRunStopsAfterAlert(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{TargetBuyPrice: 500, TargetSellPrice: 600, StatsMinutes: 10}, RearmPolicy{})
+	st.KeepRunning = false
+	tm.settings = func() (*MonitorSettings, error) { return st, nil }
+	tm.src.quotes = []*PriceQuote{quote(520), nil, quote(510), quote(499), quote(520)}
+
+	tm.Run(context.Background())
+	if len(tm.alerts) != 1 || len(tm.src.quotes) != 1 {
+		t.Errorf("%d alerts, %d quotes left, want 1 and 1", len(tm.alerts), len(tm.src.quotes))
+	}
+}
+
+// 每个品种按各自的参数判断，一个品种请求失败不影响其他品种
+func TestPollInstruments(t *testing.T) {
+	tm := newTestMonitor()
+	other := &fakeSource{name: "浙商积存金", quotes: []*PriceQuote{quote(300), nil}}
+	tm.instruments = append(tm.instruments, &monitoredInstrument{source: other})
+	st := settingsFor(Target{TargetBuyPrice: 500, TargetSellPrice: 600}, RearmPolicy{})
+	st.Targets["浙商积存金"] = Target{TargetBuyPrice: 280, TargetSellPrice: 320}
+
+	tm.src.quotes = []*PriceQuote{quote(520), quote(499)}
+	if failed, alerted := tm.poll(context.Background(), st); failed || alerted {
+		t.Errorf("round 1: failed %v, alerted %v", failed, alerted)
+	}
+	if failed, alerted := tm.poll(context.Background(), st); !failed || !alerted || len(tm.alerts) != 1 {
+		t.Errorf("round 2: failed %v, alerted %v, %d alerts", failed, alerted, len(tm.alerts))
+	}
+}
+
+// 触发后锁定，价格反向离开目标价 Rearm.Yuan 后才重新启用
+func TestBuyAlertRearmByDistance(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{BuyPrice: 520, TargetBuyPrice: 500, TargetSellPrice: 600, StatsMinutes: 10}, RearmPolicy{Yuan: 2})
+	buy := []string{"买入提醒"}
+	runSteps(t, tm, st, []step{
+		{time.Minute, 500, nil}, // 买入价 501
+		{time.Minute, 499, buy}, // 买入价 500
+		{time.Minute, 497, nil},
+		{time.Minute, 500, nil}, // 买入价 501，离开不到 2 元
+		{time.Minute, 499, nil},
+		{time.Minute, 502, nil}, // 买入价 503，重新启用
+		{time.Minute, 499, buy},
+	})
+}
+
+// 卖出提醒与买入提醒各自锁定
+func TestSellAlertRearmByDistance(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{BuyPrice: 520, TargetBuyPrice: 400, TargetSellPrice: 600, StatsMinutes: 10}, RearmPolicy{Yuan: 5})
+	sell := []string{"卖出提醒"}
+	runSteps(t, tm, st, []step{
+		{time.Minute, 601, sell}, // 回购价 600
+		{time.Minute, 610, nil},
+		{time.Minute, 597, nil}, // 回购价 596，离开 4 元
+		{time.Minute, 601, nil},
+		{time.Minute, 596, nil}, // 回购价 595，重新启用
+		{time.Minute, 605, sell},
+	})
+}
+
+// 距上次提醒超过冷却时间后，价格一直在目标价内也会再次提醒
+func TestAlertLatchCooldown(t *testing.T) {
+	p := RearmPolicy{Yuan: 100, Minutes: 30}
+	start := time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local)
+	var l alertLatch
+	for i, c := range []struct {
+		after         time.Duration
+		hit           bool
+		fire, rearmed bool
+	}{
+		{0, true, true, false},
+		{time.Minute, true, false, false},
+		{29 * time.Minute, true, false, false},
+		{30 * time.Minute, true, true, true},
+		{31 * time.Minute, false, false, false},
+		{60 * time.Minute, false, false, true}, // 冷却结束，未达到条件不提醒
+		{61 * time.Minute, true, true, false},
+	} {
+		fire, rearmed := l.update(c.hit, 1, start.Add(c.after), p)
+		if fire != c.fire || rearmed != c.rearmed {
+			t.Errorf("step %d: fire %v rearmed %v, want %v %v", i, fire, rearmed, c.fire, c.rearmed)
+		}
+	}
+}
+
+// 距上次提醒超过冷却时间后，价格一直在目标价内也会再次提醒
+func TestBuyAlertRearmByCooldown(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{BuyPrice: 520, TargetBuyPrice: 500, TargetSellPrice: 600, StatsMinutes: 10}, RearmPolicy{Yuan: 100, Minutes: 30})
+	buy := []string{"买入提醒"}
+	runSteps(t, tm, st, []step{
+		{time.Minute, 498, buy},
+		{time.Minute, 498, nil},
+		{28 * time.Minute, 498, nil}, // 距上次提醒 29 分钟
+		{time.Minute, 498, buy},
+		{time.Minute, 498, nil},
+	})
+}
+
+// 持续监控时提醒后不停止，锁定期间不重复提醒
+func TestRunKeepsRunningAfterAlert(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{TargetBuyPrice: 500, TargetSellPrice: 600, StatsMinutes: 10}, RearmPolicy{Yuan: 2})
+	tm.settings = func() (*MonitorSettings, error) { return st, nil }
+	tm.src.quotes = []*PriceQuote{quote(499), quote(498), quote(499)}
+
+	tm.Run(context.Background())
+	if !slices.Equal(tm.alerts, []string{"买入提醒"}) || len(tm.src.quotes) != 0 {
+		t.Errorf("alerts %v, %d quotes left", tm.alerts, len(tm.src.quotes))
+	}
+}
+
+// 目标卖出价低于扣费后的保本价时按保本价提醒
+func TestSellAlertAtBreakEven(t *testing.T) {
+	tm := newTestMonitor()
+	// 本金 10000 元按 500 元买入 20 克，卖出固定收费 50 元，保本回购价 502.5
+	st := settingsFor(Target{BuyPrice: 500, TargetBuyPrice: 400, TargetSellPrice: 501, StatsMinutes: 10}, RearmPolicy{Yuan: 2})
+	runSteps(t, tm, st, []step{
+		{time.Minute, 503, nil}, // 回购价 502
+		{time.Minute, 504, []string{"卖出提醒"}},
+	})
+}
+
+// 收益目标按扣费后净收益推算回购价
+func TestProfitGoalAlert(t *testing.T) {
+	tm := newTestMonitor()
+	// 净收益 100 元需回购价 507.5，2% 需 512.5，取较低者
+	st := settingsFor(Target{BuyPrice: 500, TargetBuyPrice: 400, TargetSellPrice: 600, ProfitYuan: 100, ProfitPct: 2, StatsMinutes: 10}, RearmPolicy{Yuan: 2})
+	goal := []string{"收益目标提醒"}
+	runSteps(t, tm, st, []step{
+		{time.Minute, 508, nil}, // 回购价 507
+		{time.Minute, 509, goal},
+		{time.Minute, 520, nil},
+		{time.Minute, 507, nil}, // 回购价 506，离开 1.5 元
+		{time.Minute, 505, nil}, // 回购价 504，重新启用
+		{time.Minute, 510, goal},
+	})
+}
+
+// 回购价从保本价以下回升时提醒一次
+func TestBreakEvenAlert(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{BuyPrice: 500, TargetBuyPrice: 400, TargetSellPrice: 600, BreakEvenAlert: true, StatsMinutes: 10}, RearmPolicy{Yuan: 2})
+	back := []string{"回本提醒"}
+	runSteps(t, tm, st, []step{
+		{time.Minute, 510, nil}, // 一开始就在保本价以上不提醒
+		{time.Minute, 500, nil},
+		{time.Minute, 503, nil}, // 回购价 502，保本价 502.5
+		{time.Minute, 504, back},
+		{time.Minute, 505, nil},
+		{time.Minute, 502, nil},
+		{time.Minute, 520, back},
+	})
+}
+
+// 未设置目标卖出价时只按收益目标提醒
+func TestProfitGoalWithoutTargetSell(t *testing.T) {
+	tm := newTestMonitor()
+	// 净收益 100 元需回购价 507.5
+	st := settingsFor(Target{BuyPrice: 500, TargetBuyPrice: 400, ProfitYuan: 100, StatsMinutes: 10}, RearmPolicy{Yuan: 2})
+	runSteps(t, tm, st, []step{
+		{time.Minute, 505, nil},
+		{time.Minute, 509, []string{"收益目标提醒"}},
+		{time.Minute, 520, nil},
+	})
+}
+
+func TestTargetCheck(t *testing.T) {
+	for _, c := range []struct {
+		target Target
+		ok     bool
+	}{
+		{Target{TargetBuyPrice: 500, TargetSellPrice: 600}, true},
+		{Target{TargetBuyPrice: 500, ProfitYuan: 100}, true},
+		{Target{TargetBuyPrice: 500, ProfitPct: 2}, true},
+		{Target{TargetBuyPrice: 500}, false},
+		{Target{TargetSellPrice: 600}, false},
+		{Target{TargetBuyPrice: 600, TargetSellPrice: 600}, false},
+		{Target{TargetBuyPrice: 600, TargetSellPrice: 500, ProfitYuan: 100}, false},
+	} {
+		if err := c.target.check(); (err == nil) != c.ok {
+			t.Errorf("%+v: err %v, want ok=%v", c.target, err, c.ok)
+		}
+	}
+}
+
+// 休市时报价时间不变，统计窗口按抓取时间截取，不会因为窗口为空而误报
+func TestStaleQuoteTimeKeepsWindow(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{BuyPrice: 520, TargetBuyPrice: 400, TargetSellPrice: 600, StatsMinutes: 10,
+		DropPct: 1, RisePct: 1, VolWindow: 10}, RearmPolicy{Yuan: 2})
+	for i := range 30 {
+		if got := tm.tick(st, time.Minute, 520); len(got) > 0 {
+			t.Fatalf("tick %d: unexpected alerts %v", i, got)
+		}
+	}
+	lq, ok := tm.latestQuote("工行积存金")
+	if !ok || lq.Max != 520 || lq.Min != 520 || lq.Avg != 520 {
+		t.Fatalf("stats = %+v, want max/min/avg 520", lq)
+	}
+	// 真正的涨幅仍然提醒
+	if got := tm.tick(st, time.Minute, 526); !slices.Equal(got, []string{"涨幅提醒"}) {
+		t.Errorf("got %v, want [涨幅提醒]", got)
+	}
+}
+
+// 窗口内没有记录时不判断涨跌幅
+func TestVolatilityEmptyWindow(t *testing.T) {
+	tm := newTestMonitor()
+	st := settingsFor(Target{DropPct: 1, RisePct: 1, StdMult: 1, VolWindow: 10}, RearmPolicy{})
+	ins := tm.instruments[0]
+	ins.recLis = []*PriceInfo{{T: tm.now.Add(-time.Hour).Unix(), Price: 500}}
+	if tm.checkVolatility(ins, st, 520, tm.now) || len(tm.alerts) > 0 {
+		t.Errorf("unexpected alerts %v", tm.alerts)
+	}
+}
+
+// 统计窗口内没有记录时 env 返回 nil，跳过规则
+func TestRuleSkippedWithoutStats(t *testing.T) {
+	expr, window, err := compileRule("price > 0 within 10 min")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := newRuleSet()
+	rs.rules = []*compiledRule{{Rule: Rule{ID: 1, Name: "r"}, expr: expr, window: window}}
+	now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local)
+	if got := rs.match("工行积存金", now, func(int) map[string]float64 { return nil }); len(got) != 0 {
+		t.Errorf("matched %d rules without stats", len(got))
+	}
+	if got := rs.match("工行积存金", now, func(int) map[string]float64 { return map[string]float64{"price": 520} }); len(got) != 1 {
+		t.Errorf("matched %d rules, want 1", len(got))
+	}
+}
+
+// 没有统计数据时统计变量无法计算，用到它们的规则不提醒，不会按 0、Inf 或 NaN 误判
+func TestRuleWithoutStats(t *testing.T) {
+	st := settingsFor(Target{TargetBuyPrice: 400, TargetSellPrice: 600}, RearmPolicy{})
+	for _, c := range []struct {
+		expr string
+		want []string
+	}{
+		{"drop_pct < 1", nil},       // 统计时间为 0
+		{"not (drop_pct > 1)", nil}, // 取反后仍无法计算
+		{"drop_pct < 1 or price > 0", []string{"规则提醒：r"}},
+		{"drop_pct < 1 within 10 min", []string{"规则提醒：r"}}, // 窗口内有本次报价
+	} {
+		tm := newTestMonitor()
+		expr, window, err := compileRule(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		tm.rules.rules = []*compiledRule{{Rule: Rule{ID: 1, Name: "r"}, expr: expr, window: window}}
+		if got := tm.tick(st, time.Minute, 520); !slices.Equal(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+// 尚未运行时取配置中的参数，不调用读取输入框的 settings
+func TestCurrentSettingsBeforeRun(t *testing.T) {
+	old := cfg
+	t.Cleanup(func() { cfg = old })
+	cfg.Interval = 15
+	cfg.Targets = map[string]Target{"工行积存金": {TargetBuyPrice: 500, TargetSellPrice: 600}}
+
+	tm := newTestMonitor()
+	st := settingsFor(Target{TargetBuyPrice: 400, TargetSellPrice: 700}, RearmPolicy{})
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	tm.settings = func() (*MonitorSettings, error) {
+		if calls++; calls > 1 {
+			cancel() // 第二轮停止
+		}
+		return st, nil
+	}
+	if got := tm.currentSettings(); calls != 0 || got.Interval != 15 || got.Targets["工行积存金"].TargetBuyPrice != 500 {
+		t.Errorf("before run: %+v, %d calls", got, calls)
+	}
+
+	tm.src.quotes = []*PriceQuote{quote(520)}
+	tm.Run(ctx)
+	if got := tm.currentSettings(); got != st {
+		t.Errorf("after run: %+v, want the settings of the last round", got)
+	}
+}
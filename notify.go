@@ -0,0 +1,605 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------- 远程通知 ---------- */
+
+// Notifier 远程通知渠道
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, title, content string) error
+}
+
+// 各渠道配置，对应 conf.ini 中的同名小节
+type WebhookConfig struct {
+	Enable bool   `ini:"enable"`
+	URL    string `ini:"url"`
+}
+
+type SMTPConfig struct {
+	Enable   bool   `ini:"enable"`
+	Host     string `ini:"host"`
+	Port     int    `ini:"port"`
+	User     string `ini:"user"`
+	Password string `ini:"password"`
+	From     string `ini:"from"`
+	To       string `ini:"to"` // 多个收件人用逗号分隔
+}
+
+type TelegramConfig struct {
+	Enable bool   `ini:"enable"`
+	Token  string `ini:"token"`
+	ChatID string `ini:"chat_id"`
+}
+
+// RobotConfig 钉钉/飞书/企业微信群机器人
+type RobotConfig struct {
+	Enable  bool   `ini:"enable"`
+	Webhook string `ini:"webhook"`
+	Secret  string `ini:"secret"` // 加签密钥，企业微信不需要
+}
+
+type NotifyConfig struct {
+	ServerChan bool // Server酱，key 非空时默认启用
+	Webhook    WebhookConfig
+	SMTP       SMTPConfig
+	Telegram   TelegramConfig
+	DingTalk   RobotConfig
+	Feishu     RobotConfig
+	WeCom      RobotConfig
+}
+
+// buildNotifiers 按配置创建启用的渠道
+func buildNotifiers() []Notifier {
+	var list []Notifier
+	n := cfg.Channels
+	if n.ServerChan && key != "" {
+		list = append(list, &serverChanNotifier{key: key})
+	}
+	if n.Webhook.Enable && n.Webhook.URL != "" {
+		list = append(list, &webhookNotifier{url: n.Webhook.URL})
+	}
+	if n.SMTP.Enable && n.SMTP.Host != "" && n.SMTP.To != "" {
+		list = append(list, &smtpNotifier{conf: n.SMTP})
+	}
+	if n.Telegram.Enable && n.Telegram.Token != "" && n.Telegram.ChatID != "" {
+		list = append(list, &telegramNotifier{token: n.Telegram.Token, chatID: n.Telegram.ChatID})
+	}
+	if n.DingTalk.Enable && n.DingTalk.Webhook != "" {
+		list = append(list, &dingTalkNotifier{conf: n.DingTalk})
+	}
+	if n.Feishu.Enable && n.Feishu.Webhook != "" {
+		list = append(list, &feishuNotifier{conf: n.Feishu})
+	}
+	if n.WeCom.Enable && n.WeCom.Webhook != "" {
+		list = append(list, &weComNotifier{webhook: n.WeCom.Webhook})
+	}
+	return list
+}
+
+// Dispatcher 把同一条提醒发到所有渠道，结果写入日志
+type Dispatcher struct {
+	notifiers []Notifier
+	log       func(string)
+}
+
+func newDispatcher(notifiers []Notifier, log func(string)) *Dispatcher {
+	return &Dispatcher{notifiers: notifiers, log: log}
+}
+
+// names 已启用的渠道，用于日志
+func (d *Dispatcher) names() string {
+	if len(d.notifiers) == 0 {
+		return "无"
+	}
+	return strings.Join(d.channelNames(), ",")
+}
+
+func (d *Dispatcher) channelNames() []string {
+	names := make([]string, 0, len(d.notifiers))
+	for _, n := range d.notifiers {
+		names = append(names, n.Name())
+	}
+	return names
+}
+
+// 失败重试次数与首次退避时间，之后每次翻倍
+const (
+	notifyAttempts = 4
+	notifyBackoff  = 2 * time.Second
+)
+
+// Dispatch 异步发送到所有渠道，不阻塞监控循环
+func (d *Dispatcher) Dispatch(title, content string) {
+	d.DispatchTo(nil, title, content)
+}
+
+// DispatchTo 只发送到指定名称的渠道，channels 为空表示所有渠道
+func (d *Dispatcher) DispatchTo(channels []string, title, content string) {
+	var targets []Notifier
+	for _, n := range d.notifiers {
+		if len(channels) == 0 || slices.Contains(channels, n.Name()) {
+			targets = append(targets, n)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	go func() {
+		var wg sync.WaitGroup
+		for _, n := range targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := d.deliver(n, title, content); err != nil {
+					d.log(fmt.Sprintf("通知[%s]失败: %v，已加入重发队列", n.Name(), err))
+					if err := enqueueNotification(n.Name(), title, content, err); err != nil {
+						d.log(fmt.Sprintf("通知[%s]写入重发队列失败: %v", n.Name(), err))
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+}
+
+// deliver 按指数退避重试，全部失败时返回最后一次的错误
+func (d *Dispatcher) deliver(n Notifier, title, content string) error {
+	var err error
+	backoff := notifyBackoff
+	for attempt := 1; attempt <= notifyAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = n.Send(ctx, title, content)
+		cancel()
+		if err == nil {
+			notificationsSent.inc(n.Name(), "success")
+			if attempt > 1 {
+				d.log(fmt.Sprintf("通知[%s]已发送（第%d次）", n.Name(), attempt))
+			} else {
+				d.log(fmt.Sprintf("通知[%s]已发送", n.Name()))
+			}
+			return nil
+		}
+		if attempt < notifyAttempts {
+			d.log(fmt.Sprintf("通知[%s]第%d次发送失败: %v，%v后重试", n.Name(), attempt, err, backoff))
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	notificationsSent.inc(n.Name(), "failure")
+	return err
+}
+
+// ResendQueued 重发上次未送达的通知，启动时调用
+func (d *Dispatcher) ResendQueued() {
+	items, err := loadQueuedNotifications()
+	if err != nil {
+		d.log(fmt.Sprintf("读取重发队列失败: %v", err))
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+	d.log(fmt.Sprintf("重发队列中有%d条未送达的通知", len(items)))
+
+	byName := make(map[string]Notifier, len(d.notifiers))
+	for _, n := range d.notifiers {
+		byName[n.Name()] = n
+	}
+	go func() {
+		for _, item := range items {
+			n, ok := byName[item.Channel]
+			if !ok {
+				// 渠道已停用，不再重发
+				d.log(fmt.Sprintf("通知[%s]已停用，丢弃未送达的通知: %s", item.Channel, item.Title))
+				deleteQueuedNotification(item.ID)
+				continue
+			}
+			if err := d.deliver(n, item.Title, item.Content); err != nil {
+				d.log(fmt.Sprintf("通知[%s]重发失败: %v，下次启动再试", item.Channel, err))
+				updateQueuedNotification(item.ID, err)
+				continue
+			}
+			deleteQueuedNotification(item.ID)
+		}
+	}()
+}
+
+/* ---------- 重发队列 ---------- */
+
+type queuedNotification struct {
+	ID      int64
+	Channel string
+	Title   string
+	Content string
+}
+
+func enqueueNotification(channel, title, content string, sendErr error) error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	_, err := db.Exec(`
+        INSERT INTO notify_queue(channel, title, content, created_ts, attempts, last_error)
+        VALUES(?, ?, ?, ?, ?, ?)
+    `, channel, title, content, time.Now().Format(time.RFC3339), notifyAttempts, sendErr.Error())
+	return err
+}
+
+func loadQueuedNotifications() ([]*queuedNotification, error) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(`SELECT id, channel, title, content FROM notify_queue ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*queuedNotification
+	for rows.Next() {
+		var item queuedNotification
+		if err := rows.Scan(&item.ID, &item.Channel, &item.Title, &item.Content); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+func updateQueuedNotification(id int64, sendErr error) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return
+	}
+	_, err := db.Exec(`UPDATE notify_queue SET attempts = attempts + ?, last_error = ? WHERE id = ?`,
+		notifyAttempts, sendErr.Error(), id)
+	if err != nil {
+		fmt.Printf("SQLite 写入失败: %v\n", err)
+	}
+}
+
+func deleteQueuedNotification(id int64) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return
+	}
+	if _, err := db.Exec(`DELETE FROM notify_queue WHERE id = ?`, id); err != nil {
+		fmt.Printf("SQLite 写入失败: %v\n", err)
+	}
+}
+
+var notifyClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON 发送 JSON，非 2xx 视为失败，result 非空时解析响应
+func postJSON(ctx context.Context, url string, payload, result any) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json;charset=utf-8")
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if result != nil {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("响应无法解析: %s", strings.TrimSpace(string(body)))
+		}
+	}
+	return nil
+}
+
+/* ---------- Server酱 ---------- */
+
+type serverChanNotifier struct {
+	key string
+}
+
+func (n *serverChanNotifier) Name() string { return "Server酱" }
+
+func (n *serverChanNotifier) Send(ctx context.Context, title, content string) error {
+	return scSend(ctx, n.key, title, content)
+}
+
+// sctp 开头的是 Server酱³ 的 key，格式 sctp{uid}t...
+var sctpKeyRe = regexp.MustCompile(`^sctp(\d+)t`)
+
+// scSend 发送 Server酱 通知，HTTP 状态码或响应 code 非 0 都视为失败
+func scSend(ctx context.Context, sendkey, title, desp string) error {
+	var url string
+	if strings.HasPrefix(sendkey, "sctp") {
+		m := sctpKeyRe.FindStringSubmatch(sendkey)
+		if m == nil {
+			return fmt.Errorf("无效的 sendkey 格式: %s", sendkey)
+		}
+		url = fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", m[1], sendkey)
+	} else {
+		url = fmt.Sprintf("https://sctapi.ftqq.com/%s.send", sendkey)
+	}
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err := postJSON(ctx, url, map[string]string{
+		"title": title,
+		"desp":  desp,
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("code %d: %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+/* ---------- 通用 Webhook ---------- */
+
+type webhookNotifier struct {
+	url string
+}
+
+func (n *webhookNotifier) Name() string { return "Webhook" }
+
+func (n *webhookNotifier) Send(ctx context.Context, title, content string) error {
+	return postJSON(ctx, n.url, map[string]any{
+		"title":   title,
+		"content": content,
+		"time":    time.Now().Format(time.RFC3339),
+	}, nil)
+}
+
+/* ---------- 邮件 ---------- */
+
+type smtpNotifier struct {
+	conf SMTPConfig
+}
+
+func (n *smtpNotifier) Name() string { return "邮件" }
+
+func (n *smtpNotifier) Send(ctx context.Context, title, content string) error {
+	c := n.conf
+	port := c.Port
+	if port == 0 {
+		port = 465
+	}
+	from := c.From
+	if from == "" {
+		from = c.User
+	}
+	var to []string
+	for _, addr := range strings.Split(c.To, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: =?UTF-8?B?%s?=\r\n", base64.StdEncoding.EncodeToString([]byte(title)))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	msg.WriteString(base64.StdEncoding.EncodeToString([]byte(content)))
+
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(port))
+	var auth smtp.Auth
+	if c.User != "" {
+		auth = smtp.PlainAuth("", c.User, c.Password, c.Host)
+	}
+
+	// 465 端口直接 TLS，其余端口在服务器支持时 STARTTLS，两种方式都受 ctx 超时限制
+	netDialer := &net.Dialer{Timeout: 15 * time.Second}
+	var conn net.Conn
+	var err error
+	if port == 465 {
+		dialer := &tls.Dialer{NetDialer: netDialer, Config: &tls.Config{ServerName: c.Host}}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = netDialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+/* ---------- Telegram ---------- */
+
+type telegramNotifier struct {
+	token  string
+	chatID string
+}
+
+func (n *telegramNotifier) Name() string { return "Telegram" }
+
+func (n *telegramNotifier) Send(ctx context.Context, title, content string) error {
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	err := postJSON(ctx, "https://api.telegram.org/bot"+n.token+"/sendMessage", map[string]any{
+		"chat_id": n.chatID,
+		"text":    title + "\n" + content,
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("%s", result.Description)
+	}
+	return nil
+}
+
+/* ---------- 群机器人 ---------- */
+
+// robotResult 钉钉、企业微信返回 errcode，飞书返回 code
+type robotResult struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+}
+
+func (r *robotResult) err() error {
+	if r.ErrCode != 0 {
+		return fmt.Errorf("errcode %d: %s", r.ErrCode, r.ErrMsg)
+	}
+	if r.Code != 0 {
+		return fmt.Errorf("code %d: %s", r.Code, r.Msg)
+	}
+	return nil
+}
+
+// hmacBase64 机器人加签
+func hmacBase64(key, data string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+type dingTalkNotifier struct {
+	conf RobotConfig
+}
+
+func (n *dingTalkNotifier) Name() string { return "钉钉" }
+
+func (n *dingTalkNotifier) Send(ctx context.Context, title, content string) error {
+	webhook := n.conf.Webhook
+	if n.conf.Secret != "" {
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		sign := hmacBase64(n.conf.Secret, ts+"\n"+n.conf.Secret)
+		webhook += "&timestamp=" + ts + "&sign=" + url.QueryEscape(sign)
+	}
+	var result robotResult
+	err := postJSON(ctx, webhook, map[string]any{
+		"msgtype": "text",
+		"text":    map[string]string{"content": title + "\n" + content},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	return result.err()
+}
+
+type feishuNotifier struct {
+	conf RobotConfig
+}
+
+func (n *feishuNotifier) Name() string { return "飞书" }
+
+func (n *feishuNotifier) Send(ctx context.Context, title, content string) error {
+	payload := map[string]any{
+		"msg_type": "text",
+		"content":  map[string]string{"text": title + "\n" + content},
+	}
+	if n.conf.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = ts
+		payload["sign"] = hmacBase64(ts+"\n"+n.conf.Secret, "")
+	}
+	var result robotResult
+	if err := postJSON(ctx, n.conf.Webhook, payload, &result); err != nil {
+		return err
+	}
+	return result.err()
+}
+
+type weComNotifier struct {
+	webhook string
+}
+
+func (n *weComNotifier) Name() string { return "企业微信" }
+
+func (n *weComNotifier) Send(ctx context.Context, title, content string) error {
+	var result robotResult
+	err := postJSON(ctx, n.webhook, map[string]any{
+		"msgtype": "text",
+		"text":    map[string]string{"content": title + "\n" + content},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	return result.err()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+/* ---------- 本地提醒 ---------- */
+
+// Popup 本地提醒方式
+type Popup interface {
+	Show(title, message string)
+}
+
+// newPopup 按配置选择提醒方式，auto 优先使用系统弹窗
+func newPopup(kind string, a fyne.App, w fyne.Window) Popup {
+	switch kind {
+	case "none":
+		return noopPopup{}
+	case "fyne":
+		return &fynePopup{app: a, window: w}
+	default:
+		if p := systemPopup(); p != nil {
+			return p
+		}
+		return &fynePopup{app: a, window: w}
+	}
+}
+
+// noopPopup 后台模式不弹窗
+type noopPopup struct{}
+
+func (noopPopup) Show(title, message string) {}
+
+// fynePopup 窗口内对话框加系统通知，各平台通用
+type fynePopup struct {
+	app    fyne.App
+	window fyne.Window
+}
+
+func (p *fynePopup) Show(title, message string) {
+	fyne.Do(func() {
+		dialog.ShowInformation(title, message, p.window)
+		p.window.RequestFocus()
+	})
+	p.app.SendNotification(fyne.NewNotification(title, message))
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// 非 Windows 平台没有系统弹窗，使用 Fyne
+func systemPopup() Popup {
+	return nil
+}
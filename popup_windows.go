@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/sys/windows"
+)
+
+// messageBoxPopup Windows 置顶消息框。MessageBox 会阻塞到用户点击确定，
+// 因此在单独的 goroutine 中显示，同一时间只显示一个，避免阻塞监控循环
+type messageBoxPopup struct {
+	showing atomic.Bool
+}
+
+func systemPopup() Popup {
+	return &messageBoxPopup{}
+}
+
+func (p *messageBoxPopup) Show(title, message string) {
+	// 上一个消息框尚未关闭时不再弹出，提醒仍会写入日志并发送通知
+	if !p.showing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer p.showing.Store(false)
+		// 使用简单可靠的方法创建置顶弹窗
+		caption, _ := windows.UTF16PtrFromString(title)
+		content, _ := windows.UTF16PtrFromString(message)
+		// 使用MB_TOPMOST确保置顶，MB_SETFOREGROUND确保获得焦点
+		flags := uint32(windows.MB_ICONINFORMATION | windows.MB_OK | windows.MB_SETFOREGROUND | windows.MB_TOPMOST)
+		windows.MessageBox(0, content, caption, flags)
+	}()
+}
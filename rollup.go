@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/* ---------- 数据汇总与清理 ---------- */
+
+// Candle 一根K线
+type Candle struct {
+	T     int64 // 起始时间（毫秒）
+	Open  float64
+	High  float64
+	Low   float64
+	Close float64
+	Count int // 包含的原始记录条数
+}
+
+// RetentionConfig 各级数据保留天数，0 表示永久保留
+type RetentionConfig struct {
+	RawDays    int `ini:"raw_days"`    // 原始记录
+	MinuteDays int `ini:"minute_days"` // 1分钟K线
+	HourDays   int `ini:"hour_days"`   // 1小时K线
+	DayDays    int `ini:"day_days"`    // 日K线
+	Interval   int `ini:"interval"`    // 整理间隔（分）
+}
+
+// rollupLevel 一张汇总表，由上一级汇总而来
+type rollupLevel struct {
+	table  string
+	period time.Duration
+	source string        // 来源表，price_log 为原始记录
+	chunk  time.Duration // 每次处理的时间段，避免长时间占用数据库
+	keep   func() int    // 保留天数
+}
+
+var rollupLevels = []rollupLevel{
+	{"price_1m", time.Minute, "price_log", 7 * 24 * time.Hour, func() int { return cfg.Retention.MinuteDays }},
+	{"price_1h", time.Hour, "price_1m", 30 * 24 * time.Hour, func() int { return cfg.Retention.HourDays }},
+	{"price_1d", 24 * time.Hour, "price_1h", 365 * 24 * time.Hour, func() int { return cfg.Retention.DayDays }},
+}
+
+// bucketStart 时间所在周期的起点，按本地时区对齐，日K线从本地零点开始
+func bucketStart(ts int64, period time.Duration) int64 {
+	// 整天的周期按日历日对齐，夏令时切换当天也从本地零点开始
+	if period%(24*time.Hour) == 0 {
+		y, m, d := time.UnixMilli(ts).Date()
+		days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+		n := int64(period / (24 * time.Hour))
+		days -= (days%n + n) % n
+		day := time.Unix(days*86400, 0).UTC()
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local).UnixMilli()
+	}
+	_, offset := time.UnixMilli(ts).Zone()
+	off := int64(offset) * 1000
+	p := period.Milliseconds()
+	shifted := ts + off
+	start := shifted / p * p
+	if shifted < 0 && shifted%p != 0 {
+		start -= p
+	}
+	return start - off
+}
+
+// mergeCandles 把按时间排序的K线合并为更长周期
+func mergeCandles(src []*Candle, period time.Duration) []*Candle {
+	var out []*Candle
+	var cur *Candle
+	for _, c := range src {
+		start := bucketStart(c.T, period)
+		if cur == nil || cur.T != start {
+			cur = &Candle{T: start, Open: c.Open, High: c.High, Low: c.Low, Close: c.Close}
+			out = append(out, cur)
+		}
+		cur.High = max(cur.High, c.High)
+		cur.Low = min(cur.Low, c.Low)
+		cur.Close = c.Close
+		cur.Count += c.Count
+	}
+	return out
+}
+
+// startMaintenance 启动时及之后每隔 Retention.Interval 分钟汇总并清理一次
+func startMaintenance(ctx context.Context, log func(string)) {
+	go func() {
+		for ctx.Err() == nil {
+			if err := runMaintenance(); err != nil {
+				log(fmt.Sprintf("数据整理失败: %v", err))
+			}
+			interval := cfg.Retention.Interval
+			if interval <= 0 {
+				interval = 60
+			}
+			sleepCtx(ctx, time.Duration(interval)*time.Minute)
+		}
+	}()
+}
+
+// runMaintenance 先逐级汇总，再按保留天数删除，保证删除前数据已汇总
+func runMaintenance() error {
+	now := time.Now()
+	for _, level := range rollupLevels {
+		if err := rollup(level, now); err != nil {
+			return fmt.Errorf("汇总 %s 失败: %w", level.table, err)
+		}
+	}
+
+	if err := prune("price_log", cfg.Retention.RawDays, now); err != nil {
+		return err
+	}
+	for _, level := range rollupLevels {
+		if err := prune(level.table, level.keep(), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollup 每个品种从各自上次汇总到的周期（可能不完整）开始重新汇总到当前时间，
+// 新增或补录的品种不会因其他品种已汇总到更晚的时间而被跳过
+func rollup(level rollupLevel, now time.Time) error {
+	names, err := rollupInstruments(level)
+	if err != nil {
+		return err
+	}
+	end := now.UnixMilli()
+	for _, name := range names {
+		start, ok, err := rollupStart(level, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		// 分段边界对齐到本地零点，夏令时切换时也不会把一天拆到两段中
+		for from := start; from <= end; {
+			to := bucketStart(from+level.chunk.Milliseconds(), 24*time.Hour)
+			if to <= from {
+				to = from + level.chunk.Milliseconds()
+			}
+			if err := rollupChunk(level, name, from, to); err != nil {
+				return err
+			}
+			from = to
+		}
+	}
+	return nil
+}
+
+// rollupInstruments 来源表中出现过的品种
+func rollupInstruments(level rollupLevel) ([]string, error) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return nil, nil
+	}
+
+	rows, err := db.Query("SELECT DISTINCT instrument FROM " + level.source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// rollupStart 品种在汇总表中最后一个周期，尚未汇总时取来源表中该品种最早的记录
+func rollupStart(level rollupLevel, instrument string) (int64, bool, error) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return 0, false, nil
+	}
+
+	var last sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(ts) FROM "+level.table+" WHERE instrument = ?", instrument).Scan(&last); err != nil {
+		return 0, false, err
+	}
+	if last.Valid {
+		return last.Int64, true, nil
+	}
+	var first sql.NullInt64
+	if err := db.QueryRow("SELECT MIN(ts) FROM "+level.source+" WHERE instrument = ?", instrument).Scan(&first); err != nil {
+		return 0, false, err
+	}
+	if !first.Valid {
+		return 0, false, nil
+	}
+	return bucketStart(first.Int64, level.period), true, nil
+}
+
+func rollupChunk(level rollupLevel, instrument string, from, to int64) error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	var query string
+	if level.source == "price_log" {
+		query = `SELECT ts, price, price, price, price, 1 FROM price_log
+            WHERE instrument = ? AND ts >= ? AND ts < ? ORDER BY ts`
+	} else {
+		query = `SELECT ts, open, high, low, close, count FROM ` + level.source + `
+            WHERE instrument = ? AND ts >= ? AND ts < ? ORDER BY ts`
+	}
+	rows, err := db.Query(query, instrument, from, to)
+	if err != nil {
+		return err
+	}
+	var src []*Candle
+	for rows.Next() {
+		var c Candle
+		if err := rows.Scan(&c.T, &c.Open, &c.High, &c.Low, &c.Close, &c.Count); err != nil {
+			rows.Close()
+			return err
+		}
+		src = append(src, &c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(src) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO ` + level.table + `(instrument, ts, open, high, low, close, count)
+        VALUES(?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, c := range mergeCandles(src, level.period) {
+		if _, err := stmt.Exec(instrument, c.T, c.Open, c.High, c.Low, c.Close, c.Count); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func prune(table string, days int, now time.Time) error {
+	if days <= 0 {
+		return nil
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	cutoff := now.AddDate(0, 0, -days).UnixMilli()
+	if _, err := db.Exec("DELETE FROM "+table+" WHERE ts < ?", cutoff); err != nil {
+		return fmt.Errorf("清理 %s 失败: %w", table, err)
+	}
+	return nil
+}
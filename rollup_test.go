@@ -0,0 +1,175 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestDB 在临时目录中新建数据库并执行全部升级
+func openTestDB(t *testing.T) {
+	t.Helper()
+	openTestDBAt(t, filepath.Join(t.TempDir(), "test.db"))
+}
+
+// openTestDBAt 打开并升级 path 处的数据库，测试结束时关闭
+func openTestDBAt(t *testing.T, path string) {
+	t.Helper()
+	cfg.SqlitePath = path
+	if err := initDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		dbMutex.Lock()
+		defer dbMutex.Unlock()
+		insertStmt.Close()
+		db.Close()
+		db, insertStmt = nil, nil
+	})
+}
+
+// setLocal 测试期间使用指定时区
+func setLocal(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("缺少时区数据: %v", err)
+	}
+	old := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = old })
+	return loc
+}
+
+func TestBucketStartDay(t *testing.T) {
+	ny := setLocal(t, "America/New_York")
+	// 2026-03-08 02:00 开始夏令时，2026-11-01 02:00 结束
+	for _, day := range []time.Time{
+		time.Date(2026, 3, 8, 0, 0, 0, 0, ny),
+		time.Date(2026, 11, 1, 0, 0, 0, 0, ny),
+		time.Date(2026, 6, 15, 0, 0, 0, 0, ny),
+	} {
+		for _, hour := range []int{0, 1, 3, 12, 22} {
+			ts := day.Add(time.Duration(hour) * time.Hour).UnixMilli()
+			if got := bucketStart(ts, 24*time.Hour); got != day.UnixMilli() {
+				t.Errorf("%s +%dh: got %s, want %s", day.Format("2006-01-02"), hour, time.UnixMilli(got).In(ny), day)
+			}
+		}
+	}
+}
+
+func TestBucketStartIntraday(t *testing.T) {
+	setLocal(t, "Asia/Shanghai")
+	ts := time.Date(2026, 10, 17, 9, 47, 30, 0, time.Local).UnixMilli()
+	for period, want := range map[time.Duration]time.Time{
+		time.Minute:      time.Date(2026, 10, 17, 9, 47, 0, 0, time.Local),
+		15 * time.Minute: time.Date(2026, 10, 17, 9, 45, 0, 0, time.Local),
+		time.Hour:        time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local),
+		24 * time.Hour:   time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local),
+	} {
+		if got := bucketStart(ts, period); got != want.UnixMilli() {
+			t.Errorf("%v: got %s, want %s", period, time.UnixMilli(got), want)
+		}
+	}
+}
+
+// 分段汇总跨过夏令时切换时，每天仍只有一根日K线
+func TestRollupDayAcrossDST(t *testing.T) {
+	ny := setLocal(t, "America/New_York")
+	openTestDB(t)
+
+	start := time.Date(2026, 3, 6, 0, 0, 0, 0, ny)
+	end := time.Date(2026, 3, 11, 0, 0, 0, 0, ny)
+	hours := 0
+	for ts := start; ts.Before(end); ts = ts.Add(time.Hour) {
+		_, err := db.Exec(`INSERT INTO price_1h(instrument, ts, open, high, low, close, count) VALUES('工行积存金', ?, 1, 1, 1, 1, 1)`, ts.UnixMilli())
+		if err != nil {
+			t.Fatal(err)
+		}
+		hours++
+	}
+
+	level := rollupLevel{"price_1d", 24 * time.Hour, "price_1h", 36 * time.Hour, func() int { return 0 }}
+	if err := rollup(level, end); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query(`SELECT ts, count FROM price_1d ORDER BY ts`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var days []time.Time
+	total := 0
+	for rows.Next() {
+		var ts int64
+		var count int
+		if err := rows.Scan(&ts, &count); err != nil {
+			t.Fatal(err)
+		}
+		days = append(days, time.UnixMilli(ts).In(ny))
+		total += count
+	}
+	if len(days) != 5 {
+		t.Fatalf("got %d daily rows %v, want 5", len(days), days)
+	}
+	for i, d := range days {
+		if want := start.AddDate(0, 0, i); !d.Equal(want) {
+			t.Errorf("row %d: got %s, want %s", i, d, want)
+		}
+	}
+	if total != hours {
+		t.Errorf("total count %d, want %d", total, hours)
+	}
+}
+
+// 每个品种按各自汇总到的位置继续，落后或新补录的品种不会被跳过
+func TestRollupPerInstrumentWatermark(t *testing.T) {
+	setLocal(t, "UTC")
+	openTestDB(t)
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) int64 { return base.Add(time.Duration(minutes) * time.Minute).UnixMilli() }
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 工行积存金已汇总到第 10 分钟，浙商积存金只到第 2 分钟，民生积存金是补录的更早数据
+	exec(`INSERT INTO price_1m(instrument, ts, open, high, low, close, count) VALUES('工行积存金', ?, 1, 1, 1, 1, 1)`, at(10))
+	exec(`INSERT INTO price_1m(instrument, ts, open, high, low, close, count) VALUES('浙商积存金', ?, 1, 1, 1, 1, 1)`, at(2))
+	for _, r := range []struct {
+		name    string
+		minutes int
+		price   float64
+	}{
+		{"工行积存金", 10, 500}, {"工行积存金", 11, 501},
+		{"浙商积存金", 2, 300}, {"浙商积存金", 5, 301},
+		{"民生积存金", 1, 400},
+	} {
+		exec(`INSERT INTO price_log(instrument, ts, price) VALUES(?, ?, ?)`, r.name, at(r.minutes), r.price)
+	}
+
+	level := rollupLevel{"price_1m", time.Minute, "price_log", 24 * time.Hour, func() int { return 0 }}
+	if err := rollup(level, base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct {
+		name    string
+		minutes int
+		close   float64
+	}{
+		{"工行积存金", 11, 501},
+		{"浙商积存金", 2, 300},
+		{"浙商积存金", 5, 301},
+		{"民生积存金", 1, 400},
+	} {
+		var got float64
+		err := db.QueryRow(`SELECT close FROM price_1m WHERE instrument = ? AND ts = ?`, want.name, at(want.minutes)).Scan(&got)
+		if err != nil || got != want.close {
+			t.Errorf("%s minute %d: close %v (%v), want %v", want.name, want.minutes, got, err, want.close)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+/* ---------- 提醒规则 ---------- */
+
+// Rule 自定义提醒规则，条件为表达式，如 "price < med - 3"、"max - min > 8 within 30 min"
+type Rule struct {
+	ID         int64
+	Name       string
+	Instrument string // 为空表示所有品种
+	Expr       string
+	Channels   string // 通知渠道名称，逗号分隔，为空表示所有渠道
+	Cooldown   int    // 冷却时间（分）
+	Enabled    bool
+}
+
+// 表达式中可用的变量
+var ruleVars = map[string]string{
+	"price":         "最新价",
+	"bid":           "回购价",
+	"ask":           "买入价",
+	"open":          "开盘价",
+	"high":          "最高价",
+	"low":           "最低价",
+	"change":        "涨跌",
+	"max":           "统计窗口内最高",
+	"min":           "统计窗口内最低",
+	"avg":           "统计窗口内平均",
+	"med":           "统计窗口内中位数",
+	"std":           "统计窗口内标准差",
+	"pct":           "相对统计窗口起点的涨跌幅（%）",
+	"drop_pct":      "相对统计窗口最高的跌幅（%）",
+	"rise_pct":      "相对统计窗口最低的涨幅（%）",
+	"buy":           "买入平均价格，有持仓记录时为加权平均成本",
+	"profit":        "扣除卖出手续费后的收益，有持仓时按账本，否则按本金估算",
+	"break_even":    "扣除手续费后的保本回购价",
+	"profit_target": "收益目标对应的回购价，未设置收益目标时为 0",
+	"grams":         "持仓克数",
+	"realized":      "已实现盈亏",
+	"unrealized":    "扣除卖出手续费后的浮动盈亏",
+	"target_buy":    "目标买入价格",
+	"target_sell":   "目标卖出价格",
+}
+
+// ruleVarsHelp 变量说明，按名称排序
+func ruleVarsHelp() string {
+	names := make([]string, 0, len(ruleVars))
+	for name := range ruleVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, name+": "+ruleVars[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ruleExpr 编译后的表达式，布尔值用 1/0 表示，NaN 表示无法计算（变量缺失或除以 0）
+type ruleExpr func(env map[string]float64) float64
+
+// compiledRule 规则及其编译结果
+type compiledRule struct {
+	Rule
+	expr   ruleExpr
+	window int // 统计窗口（分），0 表示使用品种的统计时间
+}
+
+func (r *compiledRule) channelList() []string {
+	var names []string
+	for _, name := range strings.Split(r.Channels, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// compileRule 解析条件表达式，末尾可带 "within N min" 指定统计窗口
+func compileRule(src string) (ruleExpr, int, error) {
+	toks, err := tokenizeRule(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	p := &ruleParser{toks: toks}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	window := 0
+	if p.peek() == "within" {
+		p.next()
+		n, err := strconv.Atoi(p.next())
+		if err != nil || n <= 0 {
+			return nil, 0, fmt.Errorf("within 后应为正整数分钟数")
+		}
+		window = n
+		switch p.peek() {
+		case "min", "m", "分钟", "分":
+			p.next()
+		}
+	}
+	if p.pos < len(p.toks) {
+		return nil, 0, fmt.Errorf("无法识别: %s", p.toks[p.pos])
+	}
+	return expr, window, nil
+}
+
+func tokenizeRule(src string) ([]string, error) {
+	var toks []string
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, string(rs[i:j]))
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			toks = append(toks, strings.ToLower(string(rs[i:j])))
+			i = j
+		default:
+			if i+1 < len(rs) {
+				two := string(rs[i : i+2])
+				switch two {
+				case "<=", ">=", "==", "!=", "&&", "||":
+					toks = append(toks, two)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/()<>!", c) {
+				return nil, fmt.Errorf("无法识别的字符: %c", c)
+			}
+			toks = append(toks, string(c))
+			i++
+		}
+	}
+	return toks, nil
+}
+
+// ruleParser 递归下降解析：or > and > not > 比较 > 加减 > 乘除 > 一元
+type ruleParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *ruleParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *ruleParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func boolVal(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// undefined 无法计算的值，比较和逻辑运算中继续传递，规则不提醒
+var undefined = math.NaN()
+
+func (p *ruleParser) parseOr() (ruleExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" || p.peek() == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env map[string]float64) float64 {
+			a, b := l(env), right(env)
+			switch {
+			case (a != 0 && !math.IsNaN(a)) || (b != 0 && !math.IsNaN(b)):
+				return 1
+			case math.IsNaN(a) || math.IsNaN(b):
+				return undefined
+			}
+			return 0
+		}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" || p.peek() == "&&" {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env map[string]float64) float64 {
+			a, b := l(env), right(env)
+			switch {
+			case a == 0 || b == 0:
+				return 0
+			case math.IsNaN(a) || math.IsNaN(b):
+				return undefined
+			}
+			return 1
+		}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseNot() (ruleExpr, error) {
+	if p.peek() == "not" || p.peek() == "!" {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(env map[string]float64) float64 {
+			v := inner(env)
+			if math.IsNaN(v) {
+				return undefined
+			}
+			return boolVal(v == 0)
+		}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *ruleParser) parseCompare() (ruleExpr, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op {
+	case "<", "<=", ">", ">=", "==", "!=":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return func(env map[string]float64) float64 {
+		a, b := left(env), right(env)
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return undefined
+		}
+		switch op {
+		case "<":
+			return boolVal(a < b)
+		case "<=":
+			return boolVal(a <= b)
+		case ">":
+			return boolVal(a > b)
+		case ">=":
+			return boolVal(a >= b)
+		case "==":
+			return boolVal(math.Abs(a-b) < 1e-9)
+		default:
+			return boolVal(math.Abs(a-b) >= 1e-9)
+		}
+	}, nil
+}
+
+func (p *ruleParser) parseSum() (ruleExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "+" {
+			left = func(env map[string]float64) float64 { return l(env) + right(env) }
+		} else {
+			left = func(env map[string]float64) float64 { return l(env) - right(env) }
+		}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseTerm() (ruleExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "*" || p.peek() == "/" {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "*" {
+			left = func(env map[string]float64) float64 { return l(env) * right(env) }
+		} else {
+			left = func(env map[string]float64) float64 {
+				d := right(env)
+				if d == 0 {
+					return undefined
+				}
+				return l(env) / d
+			}
+		}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseUnary() (ruleExpr, error) {
+	if p.peek() == "-" {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env map[string]float64) float64 { return -inner(env) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *ruleParser) parsePrimary() (ruleExpr, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("表达式不完整")
+	case t == "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("缺少右括号")
+		}
+		return inner, nil
+	case t == "abs":
+		if p.next() != "(" {
+			return nil, fmt.Errorf("abs 后应为括号")
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("缺少右括号")
+		}
+		return func(env map[string]float64) float64 { return math.Abs(inner(env)) }, nil
+	case unicode.IsDigit([]rune(t)[0]) || t[0] == '.':
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效数字: %s", t)
+		}
+		return func(map[string]float64) float64 { return v }, nil
+	default:
+		if _, ok := ruleVars[t]; !ok {
+			return nil, fmt.Errorf("未知变量: %s", t)
+		}
+		// 没有统计数据时 env 中不含统计变量
+		return func(env map[string]float64) float64 {
+			if v, ok := env[t]; ok {
+				return v
+			}
+			return undefined
+		}, nil
+	}
+}
+
+/* ---------- 规则集 ---------- */
+
+// ruleSet 监控循环使用的规则，界面修改后重新加载
+type ruleSet struct {
+	mu        sync.Mutex
+	rules     []*compiledRule
+	lastFired map[string]time.Time // 规则ID/品种 -> 上次提醒时间
+}
+
+func newRuleSet() *ruleSet {
+	return &ruleSet{lastFired: map[string]time.Time{}}
+}
+
+// reload 从数据库重新加载启用的规则，无法编译的规则跳过并返回错误
+func (rs *ruleSet) reload() error {
+	rules, err := loadRules()
+	if err != nil {
+		return err
+	}
+	var compiled []*compiledRule
+	var errs []string
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		expr, window, err := compileRule(r.Expr)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("规则[%s]: %v", r.Name, err))
+			continue
+		}
+		compiled = append(compiled, &compiledRule{Rule: *r, expr: expr, window: window})
+	}
+
+	rs.mu.Lock()
+	rs.rules = compiled
+	rs.mu.Unlock()
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// match 返回本次满足条件且不在冷却中的规则，env 按统计窗口生成变量
+func (rs *ruleSet) match(instrument string, now time.Time, env func(window int) map[string]float64) []*compiledRule {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	var fired []*compiledRule
+	for _, r := range rs.rules {
+		if r.Instrument != "" && r.Instrument != instrument {
+			continue
+		}
+		if v := r.expr(env(r.window)); v == 0 || math.IsNaN(v) {
+			continue
+		}
+		k := fmt.Sprintf("%d/%s", r.ID, instrument)
+		if last, ok := rs.lastFired[k]; ok && now.Sub(last) < time.Duration(r.Cooldown)*time.Minute {
+			continue
+		}
+		rs.lastFired[k] = now
+		fired = append(fired, r)
+	}
+	return fired
+}
+
+// maxWindow 规则中最长的统计窗口（分），用于回测时裁剪历史价格
+func (rs *ruleSet) maxWindow() int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	window := 0
+	for _, r := range rs.rules {
+		window = max(window, r.window)
+	}
+	return window
+}
+
+/* ---------- 规则存储 ---------- */
+
+func loadRules() ([]*Rule, error) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(`
+        SELECT id, name, instrument, expr, channels, cooldown, enabled
+        FROM alert_rule
+        ORDER BY id ASC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*Rule
+	for rows.Next() {
+		var r Rule
+		if err := rows.Scan(&r.ID, &r.Name, &r.Instrument, &r.Expr, &r.Channels, &r.Cooldown, &r.Enabled); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &r)
+	}
+	return rules, rows.Err()
+}
+
+// saveRule ID 为 0 时新增，否则更新
+func saveRule(r *Rule) error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if r.ID == 0 {
+		res, err := db.Exec(`
+            INSERT INTO alert_rule(name, instrument, expr, channels, cooldown, enabled)
+            VALUES(?, ?, ?, ?, ?, ?)
+        `, r.Name, r.Instrument, r.Expr, r.Channels, r.Cooldown, r.Enabled)
+		if err != nil {
+			return err
+		}
+		r.ID, err = res.LastInsertId()
+		return err
+	}
+	_, err := db.Exec(`
+        UPDATE alert_rule SET name = ?, instrument = ?, expr = ?, channels = ?, cooldown = ?, enabled = ?
+        WHERE id = ?
+    `, r.Name, r.Instrument, r.Expr, r.Channels, r.Cooldown, r.Enabled, r.ID)
+	return err
+}
+
+func deleteRule(id int64) error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	_, err := db.Exec(`DELETE FROM alert_rule WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+var ruleTestEnv = map[string]float64{"price": 500, "bid": 499, "ask": 501, "max": 510, "min": 490}
+
+func TestCompileRuleEval(t *testing.T) {
+	for _, c := range []struct {
+		src  string
+		want float64
+	}{
+		// 优先级与结合性
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"2 * 3 - 4 / 2", 4},
+		{"10 - 4 - 3", 3},
+		{"24 / 4 / 2", 3},
+		{"1 + 2 < 4", 1},
+		{"1 < 2 and 3 > 4 or 1", 1},
+		{"1 or 0 and 0", 1},
+		{"not 1 == 2", 1},
+		{"! (1 < 2) || 0", 0},
+		{"price > max - 20 && bid < ask", 1},
+		// 一元负号
+		{"-2 * 3", -6},
+		{"- -1", 1},
+		{"2 - -1", 3},
+		{"-price + 600", 100},
+		{"abs(-3 - 1)", 4},
+		{"abs(bid - ask) == 2", 1},
+		// 变量名不区分大小写
+		{"PRICE", 500},
+	} {
+		expr, _, err := compileRule(c.src)
+		if err != nil {
+			t.Errorf("%q: %v", c.src, err)
+			continue
+		}
+		if got := expr(ruleTestEnv); got != c.want {
+			t.Errorf("%q = %v, want %v", c.src, got, c.want)
+		}
+	}
+}
+
+// 除以 0 和缺失的变量无法计算，只有 or 的另一侧成立时规则成立
+func TestCompileRuleUndefined(t *testing.T) {
+	for _, c := range []struct {
+		src       string
+		undefined bool
+		want      float64
+	}{
+		{"price / 0 > 1", true, 0},
+		{"price / (bid - bid) < 1", true, 0},
+		{"not (1 / 0 > 1)", true, 0},
+		{"1 / 0 > 1 and 0", false, 0},
+		{"1 / 0 > 1 and 1", true, 0},
+		{"1 / 0 > 1 or 1", false, 1},
+		{"1 / 0 > 1 or 0", true, 0},
+		{"std > 1", true, 0}, // env 中没有 std
+		{"0 / 5", false, 0},
+	} {
+		expr, _, err := compileRule(c.src)
+		if err != nil {
+			t.Errorf("%q: %v", c.src, err)
+			continue
+		}
+		got := expr(ruleTestEnv)
+		if math.IsNaN(got) != c.undefined || (!c.undefined && got != c.want) {
+			t.Errorf("%q = %v, want undefined=%v value %v", c.src, got, c.undefined, c.want)
+		}
+	}
+}
+
+func TestCompileRuleWindow(t *testing.T) {
+	for src, want := range map[string]int{
+		"max - min > 8":                0,
+		"max - min > 8 within 30 min":  30,
+		"max - min > 8 within 5":       5,
+		"max - min > 8 within 15 分钟":   15,
+		"price < med - 3 within 60 m":  60,
+		"(max - min) > 8 within 120 分": 120,
+	} {
+		_, window, err := compileRule(src)
+		if err != nil {
+			t.Errorf("%q: %v", src, err)
+			continue
+		}
+		if window != want {
+			t.Errorf("%q: window %d, want %d", src, window, want)
+		}
+	}
+}
+
+func TestCompileRuleErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"foo > 1",                   // 未知变量
+		"price > bar",               // 未知变量
+		"1 2",                       // 多余的内容
+		"price > 1 )",               // 多余的右括号
+		"price > 1 within 10 min x", // within 后多余的内容
+		"price >",                   // 不完整
+		"(price > 1",                // 缺少右括号
+		"abs price",                 // abs 后缺少括号
+		"price > 1 within 0",        // 窗口需为正数
+		"price > 1 within",          // 缺少分钟数
+		"price # 1",                 // 无法识别的字符
+		"1..2 > 0",                  // 无效数字
+	} {
+		if _, _, err := compileRule(src); err == nil {
+			t.Errorf("%q: expected error", src)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+/* ---------- 规则编辑界面 ---------- */
+
+// showRulesWindow 列出规则，保存后监控循环立即使用新规则
+func showRulesWindow(a fyne.App, monitor *Monitor, log func(string)) {
+	w := a.NewWindow("提醒规则")
+	w.Resize(fyne.NewSize(640, 420))
+
+	var rules []*Rule
+	selected := -1
+	list := widget.NewList(
+		func() int { return len(rules) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			r := rules[i]
+			instrument := r.Instrument
+			if instrument == "" {
+				instrument = "所有品种"
+			}
+			state := "启用"
+			if !r.Enabled {
+				state = "停用"
+			}
+			o.(*widget.Label).SetText(fmt.Sprintf("[%s] %s | %s | %s | 冷却%d分", state, r.Name, instrument, r.Expr, r.Cooldown))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) { selected = id }
+	list.OnUnselected = func(widget.ListItemID) { selected = -1 }
+
+	refresh := func() {
+		var err error
+		rules, err = loadRules()
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+		if err := monitor.rules.reload(); err != nil {
+			log(fmt.Sprintf("加载提醒规则失败: %v", err))
+		}
+	}
+
+	edit := func(r *Rule) {
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(r.Name)
+		nameEntry.Validator = func(s string) error {
+			if strings.TrimSpace(s) == "" {
+				return fmt.Errorf("请输入名称")
+			}
+			return nil
+		}
+
+		instruments := append([]string{"所有品种"}, cfg.Instruments...)
+		instrumentSelect := widget.NewSelect(instruments, nil)
+		if r.Instrument == "" {
+			instrumentSelect.SetSelected("所有品种")
+		} else {
+			instrumentSelect.SetSelected(r.Instrument)
+		}
+
+		exprEntry := widget.NewEntry()
+		exprEntry.SetPlaceHolder("如 price < med - 3 或 max - min > 8 within 30 min")
+		exprEntry.SetText(r.Expr)
+		exprEntry.Validator = func(s string) error {
+			_, _, err := compileRule(s)
+			return err
+		}
+
+		cooldownEntry := widget.NewEntry()
+		cooldownEntry.SetText(strconv.Itoa(r.Cooldown))
+		cooldownEntry.Validator = func(s string) error {
+			if n, err := strconv.Atoi(s); err != nil || n < 0 {
+				return fmt.Errorf("请输入非负整数")
+			}
+			return nil
+		}
+
+		channelGroup := widget.NewCheckGroup(monitor.dispatcher.channelNames(), nil)
+		channelGroup.Horizontal = true
+		channelGroup.SetSelected((&compiledRule{Rule: *r}).channelList())
+
+		enabledCheck := widget.NewCheck("启用", nil)
+		enabledCheck.SetChecked(r.Enabled)
+
+		help := widget.NewLabel(ruleVarsHelp())
+		items := []*widget.FormItem{
+			widget.NewFormItem("名称", nameEntry),
+			widget.NewFormItem("品种", instrumentSelect),
+			widget.NewFormItem("条件", exprEntry),
+			widget.NewFormItem("冷却（分）", cooldownEntry),
+			widget.NewFormItem("通知渠道", channelGroup),
+			widget.NewFormItem("", enabledCheck),
+			widget.NewFormItem("可用变量", help),
+		}
+		d := dialog.NewForm("编辑规则", "保存", "取消", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			r.Name = strings.TrimSpace(nameEntry.Text)
+			r.Instrument = instrumentSelect.Selected
+			if r.Instrument == "所有品种" {
+				r.Instrument = ""
+			}
+			r.Expr = strings.TrimSpace(exprEntry.Text)
+			r.Cooldown, _ = strconv.Atoi(cooldownEntry.Text)
+			r.Channels = strings.Join(channelGroup.Selected, ",")
+			r.Enabled = enabledCheck.Checked
+			if err := saveRule(r); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			log(fmt.Sprintf("已保存提醒规则: %s", r.Name))
+			refresh()
+		}, w)
+		d.Resize(fyne.NewSize(600, 0))
+		d.Show()
+	}
+
+	addButton := widget.NewButton("新增", func() {
+		edit(&Rule{Cooldown: 30, Enabled: true})
+	})
+	editButton := widget.NewButton("编辑", func() {
+		if selected < 0 || selected >= len(rules) {
+			return
+		}
+		r := *rules[selected]
+		edit(&r)
+	})
+	deleteButton := widget.NewButton("删除", func() {
+		if selected < 0 || selected >= len(rules) {
+			return
+		}
+		r := rules[selected]
+		dialog.ShowConfirm("删除规则", "确定删除规则 "+r.Name+"？", func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := deleteRule(r.ID); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			log(fmt.Sprintf("已删除提醒规则: %s", r.Name))
+			refresh()
+		}, w)
+	})
+
+	refresh()
+	w.SetContent(container.NewBorder(
+		nil,
+		container.NewHBox(addButton, editButton, deleteButton),
+		nil,
+		nil,
+		list,
+	))
+	w.Show()
+}
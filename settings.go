@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+/* ---------- 界面输入保存 ---------- */
+
+// settingsSaveDelay 输入停止后多久写入数据库，避免每次按键都写
+const settingsSaveDelay = time.Second
+
+// uiSettings 界面输入值，启动时恢复，修改后延迟写入 ui_setting 表
+type uiSettings struct {
+	mu      sync.Mutex
+	saved   map[string]string
+	pending map[string]string
+	timer   *time.Timer
+	log     func(string)
+}
+
+func loadUISettings(log func(string)) *uiSettings {
+	s := &uiSettings{saved: map[string]string{}, pending: map[string]string{}, log: log}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	rows, err := db.Query(`SELECT key, value FROM ui_setting`)
+	if err != nil {
+		log(fmt.Sprintf("读取界面设置失败: %v", err))
+		return s
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			log(fmt.Sprintf("读取界面设置失败: %v", err))
+			return s
+		}
+		s.saved[k] = v
+	}
+	return s
+}
+
+// get 返回保存的值，命令行指定了对应参数时以命令行为准
+func (s *uiSettings) get(key, flagName string) (string, bool) {
+	if flagName != "" && flagGiven(flagName) {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.saved[key]
+	return v, ok
+}
+
+func (s *uiSettings) set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saved[key] == value {
+		delete(s.pending, key)
+		return
+	}
+	s.pending[key] = value
+	if s.timer == nil {
+		s.timer = time.AfterFunc(settingsSaveDelay, s.flush)
+	} else {
+		s.timer.Reset(settingsSaveDelay)
+	}
+}
+
+// flush 写入所有未保存的修改，关闭窗口时也会调用
+func (s *uiSettings) flush() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[string]string{}
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db == nil {
+		return
+	}
+	for k, v := range pending {
+		_, err := db.Exec(`INSERT OR REPLACE INTO ui_setting(key, value) VALUES(?, ?)`, k, v)
+		if err != nil {
+			s.log(fmt.Sprintf("保存界面设置失败: %v", err))
+			continue
+		}
+		s.mu.Lock()
+		s.saved[k] = v
+		s.mu.Unlock()
+	}
+}
+
+// bindEntry 恢复输入框的值，之后每次修改都保存
+func (s *uiSettings) bindEntry(e *widget.Entry, key, flagName string) {
+	if v, ok := s.get(key, flagName); ok {
+		e.SetText(v)
+	}
+	prev := e.OnChanged
+	e.OnChanged = func(text string) {
+		if prev != nil {
+			prev(text)
+		}
+		s.set(key, text)
+	}
+}
+
+// bindCheck 同 bindEntry，用于勾选框
+func (s *uiSettings) bindCheck(c *widget.Check, key, flagName string) {
+	if v, ok := s.get(key, flagName); ok {
+		c.SetChecked(v == "true")
+	}
+	prev := c.OnChanged
+	c.OnChanged = func(checked bool) {
+		if prev != nil {
+			prev(checked)
+		}
+		s.set(key, fmt.Sprint(checked))
+	}
+}
+
+// 命令行是否指定了该参数
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
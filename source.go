@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------- 价格来源 ---------- */
+
+// PriceQuote 一次报价
+type PriceQuote struct {
+	Bid    float64   // 回购价（卖出时成交的价格）
+	Ask    float64   // 买入价（买入时成交的价格）
+	Last   float64   // 最新价
+	Open   float64   // 开盘价
+	High   float64   // 最高价
+	Low    float64   // 最低价
+	Change float64   // 涨跌
+	T      time.Time // 报价时间
+}
+
+// PriceSource 价格来源，新增银行或行情只需实现该接口
+type PriceSource interface {
+	Name() string
+	Fetch(ctx context.Context) (*PriceQuote, error)
+}
+
+// quoteNum 接口中的数值有时是字符串有时是数字
+type quoteNum float64
+
+func (n *quoteNum) UnmarshalJSON(b []byte) error {
+	str := strings.Trim(string(b), `"`)
+	if str == "" || str == "null" || str == "-" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return fmt.Errorf("无效数值: %s", b)
+	}
+	*n = quoteNum(v)
+	return nil
+}
+
+// QuoteData 单个品种的报价字段
+type QuoteData struct {
+	Open   quoteNum `json:"q1"`   // 开盘价
+	High   quoteNum `json:"q3"`   // 最高价
+	Low    quoteNum `json:"q4"`   // 最低价
+	Buy    quoteNum `json:"q5"`   // 买入价
+	Sell   quoteNum `json:"q6"`   // 卖出价
+	Last   quoteNum `json:"q63"`  // 最新价
+	Name   string   `json:"q67"`  // 品种名称
+	Change quoteNum `json:"q70"`  // 涨跌
+	Time   quoteNum `json:"time"` // 报价时间（毫秒）
+}
+
+type Quote struct {
+	Data []struct {
+		QuoteData QuoteData `json:"quote"`
+	} `json:"data"`
+}
+
+var quotStrRe = regexp.MustCompile(`quot_str = \[(.+)\]`)
+
+// 同一轮内多个品种复用一次请求结果
+const feedCacheTTL = 2 * time.Second
+
+// jijinhaoFeed 抓取 api.jijinhao.com 某个分类下的全部报价
+type jijinhaoFeed struct {
+	client     *http.Client
+	categoryId string
+
+	mu        sync.Mutex
+	items     map[string]QuoteData // 品种名称(q67) -> 报价
+	fetchedAt time.Time
+}
+
+func newJijinhaoFeed(categoryId string) *jijinhaoFeed {
+	return &jijinhaoFeed{
+		client:     &http.Client{Timeout: 15 * time.Second},
+		categoryId: categoryId,
+	}
+}
+
+// source 返回该分类下某个品种的价格来源
+func (f *jijinhaoFeed) source(instrument string) *jijinhaoSource {
+	return &jijinhaoSource{feed: f, instrument: instrument}
+}
+
+func (f *jijinhaoFeed) quotes(ctx context.Context) (map[string]QuoteData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.items != nil && time.Since(f.fetchedAt) < feedCacheTTL {
+		return f.items, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.jijinhao.com/realtime/quotejs.htm", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	req.Header.Set("Referer", "https://www.cngold.org/paper/gonghang.html")
+	q := req.URL.Query()
+	q.Add("categoryId", f.categoryId)
+	q.Add("currentPage", "1")
+	q.Add("pageSize", "20")
+	q.Add("_", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := quotStrRe.FindStringSubmatch(string(body))
+	if len(matches) < 2 {
+		return nil, fmt.Errorf("无法解析 quot_str")
+	}
+
+	var quotes Quote
+	if err := json.Unmarshal([]byte(matches[1]), &quotes); err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]QuoteData, len(quotes.Data))
+	for _, item := range quotes.Data {
+		items[item.QuoteData.Name] = item.QuoteData
+	}
+	f.items = items
+	f.fetchedAt = time.Now()
+	return items, nil
+}
+
+// jijinhaoSource 报价列表中的单个品种
+type jijinhaoSource struct {
+	feed       *jijinhaoFeed
+	instrument string // 对应 q67，如 "工行积存金"
+}
+
+func (s *jijinhaoSource) Name() string {
+	return s.instrument
+}
+
+func (s *jijinhaoSource) Fetch(ctx context.Context) (*PriceQuote, error) {
+	items, err := s.feed.quotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	item, ok := items[s.instrument]
+	if !ok {
+		return nil, fmt.Errorf("未找到%s", s.instrument)
+	}
+	if item.Last <= 0 {
+		return nil, fmt.Errorf("%s 价格无效: %v", s.instrument, item.Last)
+	}
+
+	quote := &PriceQuote{
+		Bid:    float64(item.Buy),
+		Ask:    float64(item.Sell),
+		Last:   float64(item.Last),
+		Open:   float64(item.Open),
+		High:   float64(item.High),
+		Low:    float64(item.Low),
+		Change: float64(item.Change),
+		T:      time.Now(),
+	}
+	// 各银行买入/卖出字段的含义不统一，较低者为回购价
+	if quote.Bid > quote.Ask {
+		quote.Bid, quote.Ask = quote.Ask, quote.Bid
+	}
+	// 缺少买卖价的品种用最新价代替
+	if quote.Bid <= 0 {
+		quote.Bid = quote.Last
+	}
+	if quote.Ask <= 0 {
+		quote.Ask = quote.Last
+	}
+	if item.Time > 0 {
+		quote.T = time.UnixMilli(int64(item.Time))
+	}
+	return quote, nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+/* ---------- 网格与定投策略 ---------- */
+
+// Strategy 网格：比持有的最低一笔下跌 Step 元时买入 Amount 元，每笔上涨 Rise 元卖出；
+// 定投：每 Days 天买入 Amount 元，回购价高于持仓均价 Rise 元时全部卖出，Rise 为 0 不卖出
+type Strategy struct {
+	Instrument string  `ini:"instrument"` // 为空表示第一个品种
+	Kind       string  `ini:"kind"`       // grid/dca
+	Amount     float64 `ini:"amount"`     // 每笔买入金额（元），含买入手续费
+	Step       float64 `ini:"step"`       // 网格间距（元）
+	Rise       float64 `ini:"rise"`       // 上涨多少元卖出
+	Days       int     `ini:"days"`       // 定投间隔（天）
+	MaxLots    int     `ini:"max_lots"`   // 网格最多同时持有几笔，0 表示不限
+	Live       bool    `ini:"live"`       // 监控时按策略发出买卖建议
+}
+
+var strategyKinds = map[string]string{"grid": "网格", "dca": "定投"}
+
+// strategyWarmup 实时建议启动时用多长时间的历史价格恢复虚拟持仓
+const strategyWarmup = 30 * 24 * time.Hour
+
+func (s Strategy) validate(fees Fees) error {
+	switch s.Kind {
+	case "grid":
+		if s.Step <= 0 || s.Rise <= 0 {
+			return fmt.Errorf("网格策略需设置 step 和 rise")
+		}
+	case "dca":
+		if s.Days <= 0 {
+			return fmt.Errorf("定投策略需设置 days")
+		}
+	default:
+		return fmt.Errorf("未知的策略: %s，可选 grid/dca", s.Kind)
+	}
+	if s.Amount <= fees.Buy.Fee(s.Amount) {
+		return fmt.Errorf("每笔买入金额需大于买入手续费")
+	}
+	return nil
+}
+
+// describe 策略参数说明
+func (s Strategy) describe() string {
+	if s.Kind == "dca" {
+		text := fmt.Sprintf("定投：每 %d 天买入 %.2f 元", s.Days, s.Amount)
+		if s.Rise > 0 {
+			text += fmt.Sprintf("，高于持仓均价 %.2f 元全部卖出", s.Rise)
+		}
+		return text
+	}
+	return fmt.Sprintf("网格：每下跌 %.2f 元买入 %.2f 元，每笔上涨 %.2f 元卖出，最多持有 %d 笔（0 不限）", s.Step, s.Amount, s.Rise, s.MaxLots)
+}
+
+// strategyLot 未卖出的一笔买入
+type strategyLot struct {
+	grams float64
+	price float64
+}
+
+// strategyState 策略的虚拟持仓，模拟和实时建议共用
+type strategyState struct {
+	s      Strategy
+	fees   Fees
+	lots   []strategyLot
+	ref    float64 // 网格空仓时的参考买入价，价格上涨时跟随抬高
+	period int64   // 定投上次买入所在的周期
+}
+
+func newStrategyState(s Strategy, fees Fees) *strategyState {
+	return &strategyState{s: s, fees: fees, period: -1}
+}
+
+// onTick 按一次报价给出买卖，买入按买入价，卖出按回购价，T 取报价时间
+func (st *strategyState) onTick(q *PriceQuote) []*Trade {
+	var trades []*Trade
+	sell := func(grams float64) {
+		trades = append(trades, &Trade{Instrument: st.s.Instrument, T: q.T.UnixMilli(), Side: "sell",
+			Grams: grams, Price: q.Bid, Fee: st.fees.Sell.Fee(grams * q.Bid)})
+	}
+	buy := func() {
+		fee := st.fees.Buy.Fee(st.s.Amount)
+		grams := (st.s.Amount - fee) / q.Ask
+		trades = append(trades, &Trade{Instrument: st.s.Instrument, T: q.T.UnixMilli(), Side: "buy",
+			Grams: grams, Price: q.Ask, Fee: fee})
+		st.lots = append(st.lots, strategyLot{grams: grams, price: q.Ask})
+	}
+
+	switch st.s.Kind {
+	case "grid":
+		// 先卖出涨到位的各笔，全部卖出后参考价从当前价重新开始
+		var kept []strategyLot
+		for _, l := range st.lots {
+			if q.Bid >= l.price+st.s.Rise {
+				sell(l.grams)
+			} else {
+				kept = append(kept, l)
+			}
+		}
+		if len(kept) == 0 && len(st.lots) > 0 {
+			st.ref = q.Ask
+		}
+		st.lots = kept
+
+		ref := st.ref
+		if len(st.lots) == 0 {
+			st.ref = max(st.ref, q.Ask)
+			ref = st.ref
+		}
+		for _, l := range st.lots {
+			ref = min(ref, l.price)
+		}
+		if q.Ask <= ref-st.s.Step && (st.s.MaxLots <= 0 || len(st.lots) < st.s.MaxLots) {
+			buy()
+		}
+	case "dca":
+		if st.s.Rise > 0 && len(st.lots) > 0 {
+			var grams, cost float64
+			for _, l := range st.lots {
+				grams += l.grams
+				cost += st.s.Amount
+			}
+			if q.Bid >= cost/grams+st.s.Rise {
+				sell(grams)
+				st.lots = nil
+			}
+		}
+		if p := dcaPeriod(q.T, st.s.Days); p != st.period {
+			st.period = p
+			buy()
+		}
+	}
+	return trades
+}
+
+// dcaPeriod 报价所在的定投周期，按本地日期计算并以周一对齐，间隔 7 天即每周一后的第一条报价买入
+func dcaPeriod(t time.Time, days int) int64 {
+	_, offset := t.Zone()
+	day := (t.Unix() + int64(offset)) / 86400
+	return (day + 3) / int64(days) // 1970-01-01 是周四
+}
+
+/* ---------- 策略模拟 ---------- */
+
+// StrategyResult 模拟结果，盈亏都已扣除手续费
+type StrategyResult struct {
+	Strategy    Strategy
+	Ticks       int
+	Start, End  time.Time
+	Trades      []*Trade
+	Equity      []*Candle // 收益曲线（已实现加浮动盈亏），按时间汇总
+	Holding     Holding
+	LastBid     float64
+	Unrealized  float64
+	MaxDrawdown float64
+	MaxInvested float64 // 持仓成本的最大值，即最多占用的资金
+}
+
+// equityPeriod 收益曲线的汇总周期，点数不超过约 500 个
+func equityPeriod(span time.Duration) time.Duration {
+	for _, p := range []time.Duration{time.Hour, 4 * time.Hour, 24 * time.Hour} {
+		if span/p <= 500 {
+			return p
+		}
+	}
+	return 7 * 24 * time.Hour
+}
+
+// simulateStrategy 用 [from, to) 内的历史价格模拟策略
+func simulateStrategy(ctx context.Context, s Strategy, fees Fees, from, to time.Time) (*StrategyResult, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("开始时间需早于结束时间")
+	}
+	if err := s.validate(fees); err != nil {
+		return nil, err
+	}
+	res := &StrategyResult{Strategy: s}
+	st := newStrategyState(s, fees)
+	period := equityPeriod(to.Sub(from))
+	var h Holding
+	var peak float64
+	err := replayQuotes(ctx, s.Instrument, from, to, func(q *PriceQuote) {
+		for _, t := range st.onTick(q) {
+			t.ID = int64(len(res.Trades) + 1)
+			h, _ = h.apply(t) // 只卖出持有的各笔，不会出错
+			res.Trades = append(res.Trades, t)
+			res.MaxInvested = max(res.MaxInvested, h.Grams*h.AvgCost)
+		}
+
+		if res.Ticks == 0 {
+			res.Start = q.T
+		}
+		res.Ticks++
+		res.End = q.T
+		res.LastBid = q.Bid
+
+		equity := h.Realized + fees.profit(h.Grams, h.Grams*h.AvgCost, q.Bid)
+		peak = max(peak, equity)
+		res.MaxDrawdown = max(res.MaxDrawdown, peak-equity)
+		ts := q.T.UnixMilli()
+		if n := len(res.Equity); n > 0 && res.Equity[n-1].T == bucketStart(ts, period) {
+			k := res.Equity[n-1]
+			k.High = max(k.High, equity)
+			k.Low = min(k.Low, equity)
+			k.Close = equity
+			k.Count++
+		} else {
+			res.Equity = append(res.Equity, &Candle{T: bucketStart(ts, period), Open: equity, High: equity, Low: equity, Close: equity, Count: 1})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Ticks == 0 {
+		return nil, fmt.Errorf("%s 在 %s 至 %s 之间没有价格记录", s.Instrument,
+			from.Format(tradeTimeLayout), to.Format(tradeTimeLayout))
+	}
+	res.Holding = h
+	res.Unrealized = fees.profit(h.Grams, h.Grams*h.AvgCost, res.LastBid)
+	return res, nil
+}
+
+// report 模拟报告，命令行和界面共用，maxTrades 大于 0 时只列出最近的几笔成交
+func (r *StrategyResult) report(maxTrades int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "品种: %s\n", r.Strategy.Instrument)
+	fmt.Fprintf(&b, "区间: %s 至 %s，共 %d 条报价\n", r.Start.Format(tradeTimeLayout), r.End.Format(tradeTimeLayout), r.Ticks)
+	fmt.Fprintf(&b, "%s\n", r.Strategy.describe())
+
+	writeTrades(&b, r.Trades, maxTrades)
+	var buys, sells int
+	var bought float64
+	for _, t := range r.Trades {
+		if t.Side == "buy" {
+			buys++
+			bought += t.Grams*t.Price + t.Fee
+		} else {
+			sells++
+		}
+	}
+
+	h := r.Holding
+	total := h.Realized + r.Unrealized
+	fmt.Fprintf(&b, "\n买入 %d 笔共 %.2f 元，卖出 %d 笔，最多占用资金 %.2f\n", buys, bought, sells, r.MaxInvested)
+	fmt.Fprintf(&b, "已实现盈亏: %.2f\n", h.Realized)
+	if h.Grams > 0 {
+		fmt.Fprintf(&b, "期末持仓: %.4f 克，均价 %.2f，回购价 %.2f，浮动盈亏 %.2f\n", h.Grams, h.AvgCost, r.LastBid, r.Unrealized)
+	}
+	fmt.Fprintf(&b, "最终盈亏: %.2f，累计手续费: %.2f\n", total, h.Fees)
+	if r.MaxInvested > 0 {
+		fmt.Fprintf(&b, "占用资金收益率: %.2f%%\n", total/r.MaxInvested*100)
+	}
+	fmt.Fprintf(&b, "最大回撤: %.2f\n", r.MaxDrawdown)
+	return b.String()
+}
+
+// writeEquityCSV 收益曲线写入 CSV，每个汇总周期一行
+func (r *StrategyResult) writeEquityCSV(path string) error {
+	var b strings.Builder
+	b.WriteString("time,equity\n")
+	for _, k := range r.Equity {
+		fmt.Fprintf(&b, "%s,%.2f\n", time.UnixMilli(k.T).Format(tradeTimeLayout), k.Close)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// runStrategyCommand 命令行模拟，如 gold strategy -kind grid -amount 1000 -step 5 -rise 8 -from 2024-01-01
+func runStrategyCommand(args []string) error {
+	s := cfg.Strategy
+	fs := flag.NewFlagSet("strategy", flag.ContinueOnError)
+	fs.StringVar(&s.Instrument, "instrument", s.Instrument, "品种")
+	fs.StringVar(&s.Kind, "kind", s.Kind, "策略：grid 网格，dca 定投")
+	fs.Float64Var(&s.Amount, "amount", s.Amount, "每笔买入金额（元，含手续费）")
+	fs.Float64Var(&s.Step, "step", s.Step, "网格：比持有的最低一笔下跌多少元买入")
+	fs.Float64Var(&s.Rise, "rise", s.Rise, "网格：每笔上涨多少元卖出；定投：高于持仓均价多少元全部卖出，0 不卖出")
+	fs.IntVar(&s.Days, "days", s.Days, "定投间隔（天）")
+	fs.IntVar(&s.MaxLots, "lots", s.MaxLots, "网格最多同时持有几笔，0 表示不限")
+	from := fs.String("from", "", "开始日期，默认结束日期前 30 天")
+	to := fs.String("to", "", "结束日期（含当天），默认到现在")
+	equity := fs.String("equity", "", "收益曲线写入的 CSV 文件")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	end := time.Now()
+	var err error
+	if *to != "" {
+		if end, err = parseBacktestDate(*to, true); err != nil {
+			return err
+		}
+	}
+	start := end.AddDate(0, 0, -30)
+	if *from != "" {
+		if start, err = parseBacktestDate(*from, false); err != nil {
+			return err
+		}
+	}
+
+	res, err := simulateStrategy(context.Background(), s, feesFor(s.Instrument), start, end)
+	if err != nil {
+		return err
+	}
+	fmt.Print(res.report(0))
+	if *equity != "" {
+		if err := res.writeEquityCSV(*equity); err != nil {
+			return fmt.Errorf("写入收益曲线失败: %w", err)
+		}
+	}
+	return nil
+}
+
+/* ---------- 实时建议 ---------- */
+
+// newLiveStrategy 用最近的历史价格恢复虚拟持仓，之后每次报价给出的买卖作为提醒发出
+func newLiveStrategy(s Strategy) (*strategyState, error) {
+	fees := feesFor(s.Instrument)
+	if err := s.validate(fees); err != nil {
+		return nil, err
+	}
+	st := newStrategyState(s, fees)
+	now := time.Now()
+	err := replayQuotes(context.Background(), s.Instrument, now.Add(-strategyWarmup), now, func(q *PriceQuote) {
+		st.onTick(q)
+	})
+	return st, err
+}
+
+// strategyMessage 一次报价的策略建议合并为一条提醒
+func strategyMessage(s Strategy, trades []*Trade) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n品种: %s\n策略: %s", s.Instrument, s.describe())
+	for _, t := range trades {
+		fmt.Fprintf(&b, "\n建议%s %.4f 克 @ %.2f，金额 %.2f，手续费 %.2f", tradeSides[t.Side], t.Grams, t.Price, t.Grams*t.Price, t.Fee)
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+/* ---------- 策略模拟界面 ---------- */
+
+// showStrategyWindow 参数默认取 [strategy] 配置，结果显示收益曲线和成交列表
+func showStrategyWindow(a fyne.App) {
+	w := a.NewWindow("策略模拟")
+	w.Resize(fyne.NewSize(720, 720))
+	ctx, cancel := context.WithCancel(context.Background())
+	w.SetOnClosed(cancel)
+
+	s := cfg.Strategy
+	instrumentSelect := widget.NewSelect(cfg.Instruments, nil)
+	instrumentSelect.SetSelected(s.Instrument)
+	kindRadio := widget.NewRadioGroup([]string{strategyKinds["grid"], strategyKinds["dca"]}, nil)
+	kindRadio.Horizontal = true
+	kindRadio.Required = true
+	kindRadio.SetSelected(strategyKinds[s.Kind])
+
+	to := time.Now()
+	fromEntry := widget.NewEntry()
+	fromEntry.SetText(to.AddDate(0, 0, -30).Format(backtestDateLayout))
+	toEntry := widget.NewEntry()
+	toEntry.SetText(to.Format(backtestDateLayout))
+	num := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	amountEntry := widget.NewEntry()
+	amountEntry.SetText(num(s.Amount))
+	stepEntry := widget.NewEntry()
+	stepEntry.SetText(num(s.Step))
+	riseEntry := widget.NewEntry()
+	riseEntry.SetText(num(s.Rise))
+	daysEntry := widget.NewEntry()
+	daysEntry.SetText(strconv.Itoa(s.Days))
+	lotsEntry := widget.NewEntry()
+	lotsEntry.SetText(strconv.Itoa(s.MaxLots))
+	lotsEntry.SetPlaceHolder("0 表示不限")
+
+	date := func(s string) error {
+		_, err := parseBacktestDate(s, false)
+		return err
+	}
+	fromEntry.Validator = date
+	toEntry.Validator = date
+	amountEntry.Validator = positive(true, false)
+	stepEntry.Validator = nonNegative(false)
+	riseEntry.Validator = nonNegative(false)
+	daysEntry.Validator = nonNegative(true)
+	lotsEntry.Validator = nonNegative(true)
+
+	chart := newPriceChart()
+	chart.candleMode = false
+	result := widget.NewLabel("网格：比持有的最低一笔下跌指定金额时买入，每笔上涨指定金额卖出\n定投：每隔指定天数买入，上涨卖出为高于持仓均价多少元全部卖出，0 不卖出")
+
+	form := widget.NewForm(
+		widget.NewFormItem("品种", instrumentSelect),
+		widget.NewFormItem("策略", kindRadio),
+		widget.NewFormItem("开始日期", fromEntry),
+		widget.NewFormItem("结束日期（含）", toEntry),
+		widget.NewFormItem("每笔金额（元）", amountEntry),
+		widget.NewFormItem("下跌买入（元）", stepEntry),
+		widget.NewFormItem("上涨卖出（元）", riseEntry),
+		widget.NewFormItem("定投间隔（天）", daysEntry),
+		widget.NewFormItem("最多持有笔数", lotsEntry),
+	)
+	form.SubmitText = "开始模拟"
+	form.OnSubmit = func() {
+		run := s
+		run.Instrument = instrumentSelect.Selected
+		run.Kind = "grid"
+		if kindRadio.Selected == strategyKinds["dca"] {
+			run.Kind = "dca"
+		}
+		run.Amount, _ = parseNumber(amountEntry.Text, true, false)
+		run.Step, _ = parseNumber(stepEntry.Text, false, false)
+		run.Rise, _ = parseNumber(riseEntry.Text, false, false)
+		run.Days, _ = strconv.Atoi(strings.TrimSpace(daysEntry.Text))
+		run.MaxLots, _ = strconv.Atoi(strings.TrimSpace(lotsEntry.Text))
+		from, _ := parseBacktestDate(fromEntry.Text, false)
+		to, _ := parseBacktestDate(toEntry.Text, true)
+
+		form.Disable()
+		result.SetText("模拟中……")
+		go func() {
+			res, err := simulateStrategy(ctx, run, feesFor(run.Instrument), from, to)
+			fyne.Do(func() {
+				form.Enable()
+				chart.mu.Lock()
+				chart.candles = nil
+				chart.lines = nil
+				if err == nil {
+					chart.candles = res.Equity
+					chart.lines = []chartLine{{"盈亏平衡", 0, colorBuy}}
+				}
+				chart.mu.Unlock()
+				chart.Refresh()
+				if err != nil {
+					result.SetText("模拟失败: " + err.Error())
+					return
+				}
+				result.SetText(res.report(uiMaxTrades))
+			})
+		}()
+	}
+
+	w.SetContent(container.NewBorder(
+		container.NewVBox(form, widget.NewLabel("收益曲线（已实现加扣费后浮动盈亏）："), chart),
+		nil, nil, nil,
+		container.NewScroll(result),
+	))
+	w.Show()
+}
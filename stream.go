@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------- 实时推送 ---------- */
+
+// 推送参数
+const (
+	streamBacklog   = 1000             // 保留的最近事件数，断线重连时从中补发
+	streamHeartbeat = 15 * time.Second // 没有事件时发送注释行，防止代理断开空闲连接
+	streamRetry     = 3000             // 建议客户端重连等待（毫秒）
+)
+
+// streamEvent 一条推送事件，Type 为 quote 或 alert
+type streamEvent struct {
+	ID         int64
+	Type       string
+	Instrument string
+	Data       []byte // JSON
+}
+
+// eventHub 保存最近的事件并唤醒等待中的连接。
+// 事件 ID 从启动时的毫秒时间戳开始递增，重启后的 ID 总是大于重启前的
+type eventHub struct {
+	mu      sync.Mutex
+	lastID  int64
+	events  []*streamEvent // 环形缓冲，按 ID 递增
+	waiters map[chan struct{}]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		lastID:  time.Now().UnixMilli(),
+		waiters: map[chan struct{}]struct{}{},
+	}
+}
+
+// publish 追加一条事件，v 编码为 JSON
+func (h *eventHub) publish(typ, instrument string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastID++
+	h.events = append(h.events, &streamEvent{h.lastID, typ, instrument, data})
+	if len(h.events) > streamBacklog {
+		h.events = h.events[len(h.events)-streamBacklog:]
+	}
+	for ch := range h.waiters {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// since 返回 ID 大于 id 的事件。id 早于缓冲中最早的事件或不是本次运行的 ID 时 ok 为 false
+func (h *eventHub) since(id int64) (events []*streamEvent, lastID int64, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if id > h.lastID {
+		return nil, h.lastID, false
+	}
+	first := h.lastID - int64(len(h.events)) + 1
+	if id < first-1 {
+		return nil, h.lastID, false
+	}
+	return append([]*streamEvent(nil), h.events[id-first+1:]...), h.lastID, true
+}
+
+// subscribe 有新事件时 ch 可读，用完调用 unsubscribe
+func (h *eventHub) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.waiters[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan struct{}) {
+	h.mu.Lock()
+	delete(h.waiters, ch)
+	h.mu.Unlock()
+}
+
+// streamQuote quote 事件的数据，收益按当时持仓计算
+type streamQuote struct {
+	quoteJSON
+	Profit    float64 `json:"profit"`
+	BreakEven float64 `json:"break_even"`
+}
+
+// streamAlert alert 事件的数据
+type streamAlert struct {
+	Instrument string `json:"instrument"`
+	Title      string `json:"title"`
+	Message    string `json:"message"`
+	TS         int64  `json:"ts"`
+	Time       string `json:"time"`
+}
+
+func newStreamAlert(instrument, title, msg string, t time.Time) streamAlert {
+	return streamAlert{instrument, title, msg, t.UnixMilli(), t.Format(time.RFC3339)}
+}
+
+// handleStream 以 Server-Sent Events 推送报价和提醒。
+// 重连时按 Last-Event-ID（或 last_event_id 参数）补发之后的事件；
+// 无法补发时先发送 reset 事件，再发送各品种的最新报价
+func (s *apiServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	instrument := ""
+	if r.URL.Query().Get("instrument") != "" {
+		name, err := instrumentParam(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		instrument = name
+	}
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("last_event_id")
+	}
+	var lastID int64 = -1
+	if resume = strings.TrimSpace(resume); resume != "" {
+		id, err := strconv.ParseInt(resume, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Last-Event-ID 应为整数"))
+			return
+		}
+		lastID = id
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("不支持流式输出"))
+		return
+	}
+
+	hub := s.monitor.events
+	ch := hub.subscribe()
+	defer hub.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+
+	events, current, ok := hub.since(lastID)
+	if !ok {
+		if lastID >= 0 {
+			fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {\"reason\":\"事件已过期，重新发送最新报价\"}\n\n", current)
+		}
+		s.writeSnapshot(w, current, instrument)
+		lastID = current
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		for _, e := range events {
+			if instrument == "" || e.Instrument == instrument {
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+			}
+			lastID = e.ID
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			events = nil
+			continue
+		case <-ch:
+		}
+		if events, _, ok = hub.since(lastID); !ok {
+			// 连接太慢，缓冲中的事件已被覆盖
+			return
+		}
+	}
+}
+
+// writeSnapshot 以 quote 事件发送各品种的最新报价，ID 为当前最后一条事件的 ID
+func (s *apiServer) writeSnapshot(w http.ResponseWriter, id int64, instrument string) {
+	for _, name := range cfg.Instruments {
+		if instrument != "" && name != instrument {
+			continue
+		}
+		lq, ok := s.monitor.latestQuote(name)
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(streamQuote{newQuoteJSON(name, lq.Quote), lq.Profit, lq.BreakEven})
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "id: %d\nevent: quote\ndata: %s\n\n", id, data)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+/* ---------- 输入校验 ---------- */
+
+// 间隔时间范围（秒），报价缓存 2 秒，更短没有意义
+const (
+	minIntervalSeconds = 2
+	maxIntervalSeconds = 3600
+)
+
+// parseNumber 解析数字，required 为 false 时空值视为 0
+func parseNumber(s string, required, integer bool) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		if required {
+			return 0, fmt.Errorf("不能为空")
+		}
+		return 0, nil
+	}
+	if integer {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("请输入整数")
+		}
+		return float64(n), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("请输入数字")
+	}
+	return f, nil
+}
+
+// positive 大于 0 的数，非必填时可以留空
+func positive(required, integer bool) fyne.StringValidator {
+	return func(s string) error {
+		f, err := parseNumber(s, required, integer)
+		if err != nil {
+			return err
+		}
+		if f < 0 || (f == 0 && strings.TrimSpace(s) != "") {
+			return fmt.Errorf("必须大于 0")
+		}
+		return nil
+	}
+}
+
+// nonNegative 不小于 0 的数，可以留空
+func nonNegative(integer bool) fyne.StringValidator {
+	return func(s string) error {
+		f, err := parseNumber(s, false, integer)
+		if err != nil {
+			return err
+		}
+		if f < 0 {
+			return fmt.Errorf("不能小于 0")
+		}
+		return nil
+	}
+}
+
+// between 闭区间内的整数
+func between(lo, hi int) fyne.StringValidator {
+	return func(s string) error {
+		f, err := parseNumber(s, true, true)
+		if err != nil {
+			return err
+		}
+		if f < float64(lo) || f > float64(hi) {
+			return fmt.Errorf("需在 %d 到 %d 之间", lo, hi)
+		}
+		return nil
+	}
+}
+
+// targetOrder 目标买入价需低于目标卖出价，两个输入框都有效时才比较
+func targetOrder(buy, sell *widget.Entry) fyne.StringValidator {
+	return func(string) error {
+		b, err1 := strconv.ParseFloat(strings.TrimSpace(buy.Text), 64)
+		s, err2 := strconv.ParseFloat(strings.TrimSpace(sell.Text), 64)
+		if err1 == nil && err2 == nil && b >= s {
+			return fmt.Errorf("目标买入价需低于目标卖出价")
+		}
+		return nil
+	}
+}
+
+// sellOrProfit 目标卖出价可以留空，此时需设置收益目标（元或百分比）
+func sellOrProfit(profitYuan, profitPct *widget.Entry) fyne.StringValidator {
+	return func(s string) error {
+		if strings.TrimSpace(s) != "" {
+			return nil
+		}
+		yuan, _ := parseNumber(profitYuan.Text, false, false)
+		pct, _ := parseNumber(profitPct.Text, false, false)
+		if yuan <= 0 && pct <= 0 {
+			return fmt.Errorf("未设置收益目标时不能为空")
+		}
+		return nil
+	}
+}
+
+// all 依次执行多个校验，返回第一个错误
+func all(validators ...fyne.StringValidator) fyne.StringValidator {
+	return func(s string) error {
+		for _, v := range validators {
+			if err := v(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// formValidator 任一输入框修改后重新校验全部，联动校验（如买入价与卖出价）因此能同时更新
+type formValidator struct {
+	entries  []*widget.Entry
+	names    []string
+	onChange func(err error) // 第一个错误，全部有效时为 nil
+}
+
+func (f *formValidator) add(name string, e *widget.Entry, v fyne.StringValidator) {
+	e.Validator = v
+	e.AlwaysShowValidationError = true
+	f.entries = append(f.entries, e)
+	f.names = append(f.names, name)
+	prev := e.OnChanged
+	e.OnChanged = func(text string) {
+		if prev != nil {
+			prev(text)
+		}
+		f.check()
+	}
+}
+
+// check 校验所有输入框并刷新错误提示
+func (f *formValidator) check() {
+	var first error
+	for i, e := range f.entries {
+		if err := e.Validate(); err != nil && first == nil {
+			first = fmt.Errorf("%s：%v", f.names[i], err)
+		}
+	}
+	if f.onChange != nil {
+		f.onChange(first)
+	}
+}
+
+// valid 只判断是否有效，不刷新界面，可在监控协程中调用
+func (f *formValidator) valid() bool {
+	for _, e := range f.entries {
+		if e.Validator(e.Text) != nil {
+			return false
+		}
+	}
+	return true
+}